@@ -33,5 +33,9 @@ resource "aws_route53_record" "domain" {
 resource "aws_acm_certificate_validation" "domain" {
   certificate_arn         = aws_acm_certificate.domain.arn
   validation_record_fqdns = [for record in aws_route53_record.domain : record.fqdn]
+
+  timeouts {
+    create = var.validation_timeout
+  }
 }
 
@@ -5,4 +5,10 @@ variable "domain" {
 
 variable "env" {
   type    = string
-}
\ No newline at end of file
+}
+
+// DNS validation could be slow when the domain is delegated to the new zone
+variable "validation_timeout" {
+  type    = string
+  default = "75m"
+}
@@ -64,3 +64,27 @@ data "aws_iam_policy_document" "ssm_parameter_access" {
     resources = ["arn:aws:ssm:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:/${var.env}/${var.project}/task/${var.task}/*"]
   }
 }
+
+// database password for maintenance tasks, secrets are read by execution role
+resource "aws_iam_role_policy" "task_execution_db_password" {
+  count  = var.db_access ? 1 : 0
+  name   = "Task${var.task}DBPasswordPolicy"
+  role   = aws_iam_role.task_execution.id
+  policy = data.aws_iam_policy_document.db_password_access.json
+}
+
+data "aws_iam_policy_document" "db_password_access" {
+  statement {
+    actions   = ["ssm:GetParameters"]
+    resources = ["arn:aws:ssm:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:parameter/${var.env}/${var.project}/postgres_password"]
+  }
+
+  dynamic "statement" {
+    for_each = var.kms_key_arn != "" ? [var.kms_key_arn] : []
+    content {
+      actions   = ["kms:Decrypt"]
+      resources = [statement.value]
+    }
+  }
+}
+
@@ -1,7 +1,8 @@
 data "aws_region" "current" {}
+data "aws_caller_identity" "current" {}
 
 resource "aws_scheduler_schedule_group" "group" {
-  name = "${var.project}-schedule-group-${var.env}"
+  name = "${var.project}-schedule-group-${var.task}-${var.env}"
 }
 
 resource "aws_scheduler_schedule" "scheduler" {
@@ -24,7 +25,7 @@ resource "aws_scheduler_schedule" "scheduler" {
       launch_type = "FARGATE"
       
       network_configuration {
-        assign_public_ip = false
+        assign_public_ip = true
         security_groups = [aws_security_group.task.id] 
         subnets = var.subnet_ids 
       } 
@@ -33,8 +34,13 @@ resource "aws_scheduler_schedule" "scheduler" {
 }
 
 resource "aws_ecr_repository" "task" {
-  name  = "${var.project}_task_${var.task}"
-  count = var.env == "dev" ? 1 : 0
+  name                 = "${var.project}_task_${var.task}"
+  count                = var.env == "dev" ? 1 : 0
+  image_tag_mutability = var.ecr_image_tag_mutability
+
+  image_scanning_configuration {
+    scan_on_push = var.ecr_scan_on_push
+  }
 
   tags = {
     terraform = "true"
@@ -48,6 +54,39 @@ resource "aws_ecr_repository_policy" "task" {
   count      = var.env == "dev" ? 1 : 0
 }
 
+resource "aws_ecr_lifecycle_policy" "task" {
+  repository = join("", aws_ecr_repository.task.*.name)
+  count      = var.env == "dev" && var.ecr_keep_images > 0 ? 1 : 0
+  policy = jsonencode({
+    rules = [
+      {
+        rulePriority = 1
+        description  = "Delete untagged images"
+        selection = {
+          tagStatus   = "untagged"
+          countType   = "imageCountMoreThan"
+          countNumber = 1
+        }
+        action = {
+          type = "expire"
+        }
+      },
+      {
+        rulePriority = 2
+        description  = "Keep no more than ${var.ecr_keep_images} recent images"
+        selection = {
+          tagStatus   = "any"
+          countType   = "imageCountMoreThan"
+          countNumber = var.ecr_keep_images
+        }
+        action = {
+          type = "expire"
+        }
+      }
+    ]
+  })
+}
+
 
 resource "aws_ecs_task_definition" "task" {
   network_mode             = "awsvpc"
@@ -62,8 +101,10 @@ resource "aws_ecs_task_definition" "task" {
     name   = "${var.project}_container_${var.task}_${var.env}"
     cpu    = 256
     memory = 512
-    image  = "${var.env == "dev" ? join("", aws_ecr_repository.task.*.repository_url) : var.ecr_url}:latest"
-    secrets     = local.task_env_ssm
+    image  = var.image != "" ? var.image : "${var.env == "dev" ? join("", aws_ecr_repository.task.*.repository_url) : var.ecr_url}:latest"
+    command     = length(var.command) > 0 ? var.command : null
+    secrets     = concat(local.task_env_ssm, local.task_db_secrets)
+    environment = local.task_db_env
 
     essential = true
 
@@ -95,4 +136,17 @@ resource "aws_cloudwatch_log_group" "task" {
   }
 }
 
+// database connection in libpq env variables, so psql, vacuumdb and pg_dump work without arguments
+locals {
+  task_db_env = var.db_access ? [
+    { "name" : "PGHOST", "value" : var.db_endpoint },
+    { "name" : "PGPORT", "value" : tostring(var.db_port) },
+    { "name" : "PGDATABASE", "value" : var.db_name },
+    { "name" : "PGUSER", "value" : var.db_user },
+  ] : []
+
+  task_db_secrets = var.db_access ? [
+    { "name" : "PGPASSWORD", "valueFrom" : "/${var.env}/${var.project}/postgres_password" },
+  ] : []
+}
 
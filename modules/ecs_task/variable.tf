@@ -21,6 +21,29 @@ variable "ecr_url" {
   default = ""
 }
 
+// task ECR repository settings, MUTABLE or IMMUTABLE tags
+variable "ecr_image_tag_mutability" {
+  type    = string
+  default = "MUTABLE"
+}
+
+variable "ecr_scan_on_push" {
+  type    = bool
+  default = false
+}
+
+// keep no more than N recent images, 0 to keep all of them
+variable "ecr_keep_images" {
+  type    = number
+  default = 0
+}
+
+// accounts outside of the organization allowed to pull images
+variable "ecr_pull_account_ids" {
+  type    = list(string)
+  default = []
+}
+
 # https://docs.aws.amazon.com/scheduler/latest/UserGuide/schedule-types.html?icmpid=docs_console_unmapped#rate-based
 variable "schedule" {
   type    = string
@@ -87,6 +110,24 @@ data "aws_iam_policy_document" "default_ecr_policy" {
       values = [ data.aws_organizations_organization.org.id ]
     }
   }
+
+  dynamic "statement" {
+    for_each = length(var.ecr_pull_account_ids) > 0 ? [1] : []
+    content {
+      sid = "Cross account read ECR policy"
+      principals {
+        type        = "AWS"
+        identifiers = [for id in var.ecr_pull_account_ids : "arn:aws:iam::${id}:root"]
+      }
+      actions = [
+        "ecr:BatchCheckLayerAvailability",
+        "ecr:BatchGetImage",
+        "ecr:DescribeImages",
+        "ecr:DescribeRepositories",
+        "ecr:GetDownloadUrlForLayer"
+      ]
+    }
+  }
 }
 
 
@@ -103,3 +144,47 @@ locals {
     }
   ]
 }
+
+// image to run instead of the task ECR repository image, e.g. postgres:14-alpine for database maintenance
+variable "image" {
+  type    = string
+  default = ""
+}
+
+// container command, the image command is used if empty
+variable "command" {
+  type    = list(string)
+  default = []
+}
+
+// pass the database connection to the task, e.g. for vacuum or partition rotation
+variable "db_access" {
+  type    = bool
+  default = false
+}
+
+// customer managed key of the database password, if any
+variable "kms_key_arn" {
+  type    = string
+  default = ""
+}
+
+variable "db_endpoint" {
+  type    = string
+  default = ""
+}
+
+variable "db_port" {
+  type    = number
+  default = 5432
+}
+
+variable "db_name" {
+  type    = string
+  default = ""
+}
+
+variable "db_user" {
+  type    = string
+  default = ""
+}
@@ -1,8 +1,13 @@
 data "aws_region" "current" {}
 
 resource "aws_ecr_repository" "task" {
-  name  = "${var.project}_task_${var.task}"
-  count = var.env == "dev" ? 1 : 0
+  name                 = "${var.project}_task_${var.task}"
+  count                = var.env == "dev" ? 1 : 0
+  image_tag_mutability = var.ecr_image_tag_mutability
+
+  image_scanning_configuration {
+    scan_on_push = var.ecr_scan_on_push
+  }
 
   tags = {
     terraform = "true"
@@ -16,6 +21,39 @@ resource "aws_ecr_repository_policy" "task" {
   count      = var.env == "dev" ? 1 : 0
 }
 
+resource "aws_ecr_lifecycle_policy" "task" {
+  repository = join("", aws_ecr_repository.task.*.name)
+  count      = var.env == "dev" && var.ecr_keep_images > 0 ? 1 : 0
+  policy = jsonencode({
+    rules = [
+      {
+        rulePriority = 1
+        description  = "Delete untagged images"
+        selection = {
+          tagStatus   = "untagged"
+          countType   = "imageCountMoreThan"
+          countNumber = 1
+        }
+        action = {
+          type = "expire"
+        }
+      },
+      {
+        rulePriority = 2
+        description  = "Keep no more than ${var.ecr_keep_images} recent images"
+        selection = {
+          tagStatus   = "any"
+          countType   = "imageCountMoreThan"
+          countNumber = var.ecr_keep_images
+        }
+        action = {
+          type = "expire"
+        }
+      }
+    ]
+  })
+}
+
 resource "aws_ecs_task_definition" "task" {
   network_mode             = "awsvpc"
   requires_compatibilities = ["FARGATE"]
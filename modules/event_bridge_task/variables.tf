@@ -19,6 +19,29 @@ variable "ecr_url" {
   default = ""
 }
 
+// task ECR repository settings, MUTABLE or IMMUTABLE tags
+variable "ecr_image_tag_mutability" {
+  type    = string
+  default = "MUTABLE"
+}
+
+variable "ecr_scan_on_push" {
+  type    = bool
+  default = false
+}
+
+// keep no more than N recent images, 0 to keep all of them
+variable "ecr_keep_images" {
+  type    = number
+  default = 0
+}
+
+// accounts outside of the organization allowed to pull images
+variable "ecr_pull_account_ids" {
+  type    = list(string)
+  default = []
+}
+
 variable "subnet_ids" {
   type = list(string)
 }
@@ -96,6 +119,24 @@ data "aws_iam_policy_document" "default_ecr_policy" {
       values = [ data.aws_organizations_organization.org.id ]
     }
   }
+
+  dynamic "statement" {
+    for_each = length(var.ecr_pull_account_ids) > 0 ? [1] : []
+    content {
+      sid = "Cross account read ECR policy"
+      principals {
+        type        = "AWS"
+        identifiers = [for id in var.ecr_pull_account_ids : "arn:aws:iam::${id}:root"]
+      }
+      actions = [
+        "ecr:BatchCheckLayerAvailability",
+        "ecr:BatchGetImage",
+        "ecr:DescribeImages",
+        "ecr:DescribeRepositories",
+        "ecr:GetDownloadUrlForLayer"
+      ]
+    }
+  }
 }
 
 data "aws_ssm_parameters_by_path" "task" {
@@ -0,0 +1,17 @@
+// Customer managed key of the environment for S3, RDS and SSM encryption.
+// The default key policy delegates access to IAM, so every role using the key needs kms permissions in its policy.
+resource "aws_kms_key" "main" {
+  description             = "${var.project} ${var.env} encryption key"
+  enable_key_rotation     = true
+  deletion_window_in_days = var.deletion_window
+
+  tags = {
+    terraform = "true"
+    env       = var.env
+  }
+}
+
+resource "aws_kms_alias" "main" {
+  name          = "alias/${var.project}-${var.env}"
+  target_key_id = aws_kms_key.main.key_id
+}
@@ -0,0 +1,7 @@
+output "arn" {
+  value = aws_kms_key.main.arn
+}
+
+output "alias" {
+  value = aws_kms_alias.main.name
+}
@@ -0,0 +1,12 @@
+variable "project" {
+  type = string
+}
+
+variable "env" {
+  type = string
+}
+
+variable "deletion_window" {
+  type    = number
+  default = 30
+}
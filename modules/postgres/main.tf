@@ -10,5 +10,19 @@ resource "aws_db_instance" "database" {
   password               = aws_ssm_parameter.postgres_password.value
   skip_final_snapshot    = true
   vpc_security_group_ids = [aws_security_group.database.id]
+  db_subnet_group_name   = length(var.subnet_ids) > 0 ? aws_db_subnet_group.database[0].name : null
+
+  timeouts {
+    create = var.create_timeout
+    update = var.update_timeout
+    delete = var.delete_timeout
+  }
+}
+
+// default VPC has the default subnet group, other VPCs need own
+resource "aws_db_subnet_group" "database" {
+  count      = length(var.subnet_ids) > 0 ? 1 : 0
+  name       = "${var.project}-postgres-${var.env}"
+  subnet_ids = var.subnet_ids
 }
 
@@ -1,3 +1,7 @@
+output "identifier" {
+  value =  aws_db_instance.database.identifier
+}
+
 output "endpoint" {
   value =  aws_db_instance.database.address
 }
@@ -9,6 +9,12 @@ variable "vpc_id" {
 }
 
 
+// subnets of the database, the default subnet group is used if empty
+variable "subnet_ids" {
+  type = list(string)
+  default = []
+}
+
 variable "project" {
   type = string
 }
@@ -32,6 +38,27 @@ variable "storage" {
   default = "20"
 }
 
+// customer managed key for the password parameters, AWS managed key is used if empty
+variable "kms_key_arn" {
+  type = string
+  default = ""
+}
+
+variable "create_timeout" {
+  type = string
+  default = "40m"
+}
+
+variable "update_timeout" {
+  type = string
+  default = "80m"
+}
+
+variable "delete_timeout" {
+  type = string
+  default = "60m"
+}
+
 resource "random_password" "postgres" {
   length           = 16
   special          = true
@@ -41,6 +68,7 @@ resource "random_password" "postgres" {
 resource "aws_ssm_parameter" "postgres_password" {
   name = "/${var.env}/${var.project}/postgres_password"
   type = "SecureString"
+  key_id = var.kms_key_arn != "" ? var.kms_key_arn : null
   value = random_password.postgres.result
 }
 
@@ -48,5 +76,6 @@ resource "aws_ssm_parameter" "postgres_password" {
 resource "aws_ssm_parameter" "postgres_password_backend" {
   name = "/${var.env}/${var.project}/backend/pg_database_password"
   type = "SecureString"
+  key_id = var.kms_key_arn != "" ? var.kms_key_arn : null
   value = random_password.postgres.result
 }
\ No newline at end of file
@@ -6,6 +6,17 @@ resource "aws_lb" "alb" {
   subnets            = var.subnet_ids
 
   enable_deletion_protection = false
+
+  dynamic "access_logs" {
+    for_each = var.alb_access_logs ? [1] : []
+    content {
+      bucket  = local.alb_logs_bucket
+      prefix  = local.alb_logs_prefix
+      enabled = true
+    }
+  }
+
+  depends_on = [aws_s3_bucket_policy.alb_logs]
 }
 
 resource "aws_alb_listener" "http" {
@@ -0,0 +1,203 @@
+// ALB access logs in S3, queried with Athena, enabled with alb_access_logs.
+// https://docs.aws.amazon.com/athena/latest/ug/application-load-balancer-logs.html
+data "aws_elb_service_account" "main" {}
+
+locals {
+  alb_logs_bucket   = "${var.project}-alb-logs-${var.env}${var.image_bucket_postfix}"
+  alb_logs_prefix   = "alb"
+  alb_logs_location = "s3://${local.alb_logs_bucket}/${local.alb_logs_prefix}/AWSLogs/${data.aws_caller_identity.current.account_id}/elasticloadbalancing/${data.aws_region.current.name}"
+
+  alb_logs_columns = [
+    { name = "type", type = "string" },
+    { name = "time", type = "string" },
+    { name = "elb", type = "string" },
+    { name = "client_ip", type = "string" },
+    { name = "client_port", type = "int" },
+    { name = "target_ip", type = "string" },
+    { name = "target_port", type = "int" },
+    { name = "request_processing_time", type = "double" },
+    { name = "target_processing_time", type = "double" },
+    { name = "response_processing_time", type = "double" },
+    { name = "elb_status_code", type = "int" },
+    { name = "target_status_code", type = "string" },
+    { name = "received_bytes", type = "bigint" },
+    { name = "sent_bytes", type = "bigint" },
+    { name = "request_verb", type = "string" },
+    { name = "request_url", type = "string" },
+    { name = "request_proto", type = "string" },
+    { name = "user_agent", type = "string" },
+    { name = "ssl_cipher", type = "string" },
+    { name = "ssl_protocol", type = "string" },
+    { name = "target_group_arn", type = "string" },
+    { name = "trace_id", type = "string" },
+    { name = "domain_name", type = "string" },
+    { name = "chosen_cert_arn", type = "string" },
+    { name = "matched_rule_priority", type = "string" },
+    { name = "request_creation_time", type = "string" },
+    { name = "actions_executed", type = "string" },
+    { name = "redirect_url", type = "string" },
+    { name = "lambda_error_reason", type = "string" },
+    { name = "target_port_list", type = "string" },
+    { name = "target_status_code_list", type = "string" },
+    { name = "classification", type = "string" },
+    { name = "classification_reason", type = "string" },
+    { name = "conn_trace_id", type = "string" },
+  ]
+
+  alb_logs_regex = <<-EOT
+([^ ]*) ([^ ]*) ([^ ]*) ([^ ]*):([0-9]*) ([^ ]*)[:-]([0-9]*) ([-.0-9]*) ([-.0-9]*) ([-.0-9]*) (|[-0-9]*) (-|[-0-9]*) ([-0-9]*) ([-0-9]*) "([^ ]*) (.*) (- |[^ ]*)" "([^"]*)" ([A-Z0-9-_]+) ([A-Za-z0-9.-]*) ([^ ]*) "([^"]*)" "([^"]*)" "([^"]*)" ([-.0-9]*) ([^ ]*) "([^"]*)" "([^"]*)" "([^ ]*)" "([^ ]+?)" "([^ ]+)" "([^ ]*)" "([^ ]*)" ?([^ ]*)?(?: .*)?
+  EOT
+}
+
+resource "aws_s3_bucket" "alb_logs" {
+  count  = var.alb_access_logs ? 1 : 0
+  bucket = local.alb_logs_bucket
+
+  tags = {
+    terraform = "true"
+    env       = var.env
+  }
+}
+
+resource "aws_s3_bucket_lifecycle_configuration" "alb_logs" {
+  count  = var.alb_access_logs ? 1 : 0
+  bucket = aws_s3_bucket.alb_logs[0].id
+
+  rule {
+    id     = "expire"
+    status = "Enabled"
+
+    filter {}
+
+    expiration {
+      days = var.alb_access_logs_retention
+    }
+  }
+}
+
+resource "aws_s3_bucket_policy" "alb_logs" {
+  count  = var.alb_access_logs ? 1 : 0
+  bucket = aws_s3_bucket.alb_logs[0].id
+  policy = data.aws_iam_policy_document.alb_logs.json
+}
+
+data "aws_iam_policy_document" "alb_logs" {
+  statement {
+    principals {
+      type        = "AWS"
+      identifiers = [data.aws_elb_service_account.main.arn]
+    }
+    actions   = ["s3:PutObject"]
+    resources = ["arn:aws:s3:::${local.alb_logs_bucket}/${local.alb_logs_prefix}/*"]
+  }
+}
+
+resource "aws_glue_catalog_database" "alb_logs" {
+  count = var.alb_access_logs ? 1 : 0
+  name  = "${var.project}_alb_logs_${var.env}"
+}
+
+resource "aws_glue_catalog_table" "alb_logs" {
+  count         = var.alb_access_logs ? 1 : 0
+  name          = "alb_logs"
+  database_name = aws_glue_catalog_database.alb_logs[0].name
+  table_type    = "EXTERNAL_TABLE"
+
+  // partition projection, no need to add partitions for the new days
+  parameters = {
+    EXTERNAL                       = "TRUE"
+    "projection.enabled"           = "true"
+    "projection.day.type"          = "date"
+    "projection.day.format"        = "yyyy/MM/dd"
+    "projection.day.range"         = "2023/01/01,NOW"
+    "projection.day.interval"      = "1"
+    "projection.day.interval.unit" = "DAYS"
+    "storage.location.template"    = "${local.alb_logs_location}/$${day}"
+  }
+
+  partition_keys {
+    name = "day"
+    type = "string"
+  }
+
+  storage_descriptor {
+    location      = local.alb_logs_location
+    input_format  = "org.apache.hadoop.mapred.TextInputFormat"
+    output_format = "org.apache.hadoop.hive.ql.io.HiveIgnoreKeyTextOutputFormat"
+
+    ser_de_info {
+      serialization_library = "org.apache.hadoop.hive.serde2.RegexSerDe"
+      parameters = {
+        "serialization.format" = "1"
+        "input.regex"          = trimspace(local.alb_logs_regex)
+      }
+    }
+
+    dynamic "columns" {
+      for_each = local.alb_logs_columns
+      content {
+        name = columns.value.name
+        type = columns.value.type
+      }
+    }
+  }
+}
+
+resource "aws_athena_workgroup" "alb_logs" {
+  count         = var.alb_access_logs ? 1 : 0
+  name          = "${var.project}_alb_logs_${var.env}"
+  force_destroy = true
+
+  configuration {
+    result_configuration {
+      output_location = "s3://${aws_s3_bucket.alb_logs[0].id}/athena/"
+    }
+  }
+}
+
+// query presets, open them in Athena console saved queries of the workgroup
+resource "aws_athena_named_query" "alb_top_ips" {
+  count     = var.alb_access_logs ? 1 : 0
+  name      = "top_ips"
+  workgroup = aws_athena_workgroup.alb_logs[0].id
+  database  = aws_glue_catalog_database.alb_logs[0].name
+  query     = <<-EOT
+    SELECT client_ip, count(*) AS requests
+    FROM alb_logs
+    WHERE day >= date_format(current_date - interval '1' day, '%Y/%m/%d')
+    GROUP BY client_ip
+    ORDER BY requests DESC
+    LIMIT 50
+  EOT
+}
+
+resource "aws_athena_named_query" "alb_5xx_by_path" {
+  count     = var.alb_access_logs ? 1 : 0
+  name      = "5xx_by_path"
+  workgroup = aws_athena_workgroup.alb_logs[0].id
+  database  = aws_glue_catalog_database.alb_logs[0].name
+  query     = <<-EOT
+    SELECT request_verb, url_extract_path(request_url) AS path, elb_status_code, count(*) AS requests
+    FROM alb_logs
+    WHERE day >= date_format(current_date - interval '1' day, '%Y/%m/%d') AND elb_status_code >= 500
+    GROUP BY 1, 2, 3
+    ORDER BY requests DESC
+    LIMIT 50
+  EOT
+}
+
+resource "aws_athena_named_query" "alb_p99_latency_by_route" {
+  count     = var.alb_access_logs ? 1 : 0
+  name      = "p99_latency_by_route"
+  workgroup = aws_athena_workgroup.alb_logs[0].id
+  database  = aws_glue_catalog_database.alb_logs[0].name
+  query     = <<-EOT
+    SELECT request_verb, url_extract_path(request_url) AS path, count(*) AS requests,
+      approx_percentile(target_processing_time, 0.99) AS p99_seconds
+    FROM alb_logs
+    WHERE day >= date_format(current_date - interval '1' day, '%Y/%m/%d') AND target_processing_time >= 0
+    GROUP BY 1, 2
+    ORDER BY p99_seconds DESC
+    LIMIT 50
+  EOT
+}
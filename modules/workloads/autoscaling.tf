@@ -0,0 +1,59 @@
+// Backend target tracking autoscaling, enabled when backend_autoscaling_max is set.
+// Every policy is skipped when its target is not set.
+resource "aws_appautoscaling_target" "backend" {
+  count              = var.backend_autoscaling_max > 0 ? 1 : 0
+  service_namespace  = "ecs"
+  resource_id        = "service/${aws_ecs_cluster.main.name}/${aws_ecs_service.backend.name}"
+  scalable_dimension = "ecs:service:DesiredCount"
+  min_capacity       = var.backend_autoscaling_min
+  max_capacity       = var.backend_autoscaling_max
+}
+
+resource "aws_appautoscaling_policy" "backend_cpu" {
+  count              = var.backend_autoscaling_max > 0 && var.backend_autoscaling_cpu > 0 ? 1 : 0
+  name               = "${var.project}_backend_cpu_${var.env}"
+  policy_type        = "TargetTrackingScaling"
+  service_namespace  = aws_appautoscaling_target.backend[0].service_namespace
+  resource_id        = aws_appautoscaling_target.backend[0].resource_id
+  scalable_dimension = aws_appautoscaling_target.backend[0].scalable_dimension
+
+  target_tracking_scaling_policy_configuration {
+    target_value = var.backend_autoscaling_cpu
+    predefined_metric_specification {
+      predefined_metric_type = "ECSServiceAverageCPUUtilization"
+    }
+  }
+}
+
+resource "aws_appautoscaling_policy" "backend_memory" {
+  count              = var.backend_autoscaling_max > 0 && var.backend_autoscaling_memory > 0 ? 1 : 0
+  name               = "${var.project}_backend_memory_${var.env}"
+  policy_type        = "TargetTrackingScaling"
+  service_namespace  = aws_appautoscaling_target.backend[0].service_namespace
+  resource_id        = aws_appautoscaling_target.backend[0].resource_id
+  scalable_dimension = aws_appautoscaling_target.backend[0].scalable_dimension
+
+  target_tracking_scaling_policy_configuration {
+    target_value = var.backend_autoscaling_memory
+    predefined_metric_specification {
+      predefined_metric_type = "ECSServiceAverageMemoryUtilization"
+    }
+  }
+}
+
+resource "aws_appautoscaling_policy" "backend_requests" {
+  count              = var.backend_autoscaling_max > 0 && var.backend_autoscaling_requests > 0 ? 1 : 0
+  name               = "${var.project}_backend_requests_${var.env}"
+  policy_type        = "TargetTrackingScaling"
+  service_namespace  = aws_appautoscaling_target.backend[0].service_namespace
+  resource_id        = aws_appautoscaling_target.backend[0].resource_id
+  scalable_dimension = aws_appautoscaling_target.backend[0].scalable_dimension
+
+  target_tracking_scaling_policy_configuration {
+    target_value = var.backend_autoscaling_requests
+    predefined_metric_specification {
+      predefined_metric_type = "ALBRequestCountPerTarget"
+      resource_label         = "${aws_lb.alb.arn_suffix}/${aws_alb_target_group.backend.arn_suffix}"
+    }
+  }
+}
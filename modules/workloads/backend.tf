@@ -19,13 +19,13 @@ resource "aws_ecs_service" "backend" {
   name                               = "backend_service_${var.env}"
   cluster                            = aws_ecs_cluster.main.id
   task_definition                    = "${aws_ecs_task_definition.backend.family}:${max(aws_ecs_task_definition.backend.revision, data.aws_ecs_task_definition.backend.revision)}"
-  desired_count                      = 1
+  desired_count                      = var.backend_autoscaling_max > 0 ? var.backend_autoscaling_min : 1
   deployment_minimum_healthy_percent = 50
   launch_type                        = "FARGATE"
   scheduling_strategy                = "REPLICA"
 
   network_configuration {
-    security_groups  = [aws_security_group.backend.id]
+    security_groups  = concat([aws_security_group.backend.id], var.backend_security_group_ids)
     subnets          = var.subnet_ids
     assign_public_ip = true
   }
@@ -40,8 +40,9 @@ resource "aws_ecs_service" "backend" {
     registry_arn = aws_service_discovery_service.backend.arn
   }
 
+  // desired count is managed by autoscaling and hibernation after the service is created
   lifecycle {
-    ignore_changes = [task_definition]
+    ignore_changes = [task_definition, desired_count]
   }
 
   tags = {
@@ -139,6 +140,19 @@ resource "aws_s3_bucket" "images" {
   }
 }
 
+resource "aws_s3_bucket_server_side_encryption_configuration" "images" {
+  count  = var.enable_kms ? 1 : 0
+  bucket = aws_s3_bucket.images.id
+
+  rule {
+    apply_server_side_encryption_by_default {
+      sse_algorithm     = "aws:kms"
+      kms_master_key_id = var.kms_key_arn
+    }
+    bucket_key_enabled = true
+  }
+}
+
 resource "aws_s3_bucket_ownership_controls" "images" {
   bucket = aws_s3_bucket.images.id
   rule {
@@ -251,3 +265,48 @@ data "aws_iam_policy_document" "ssm_parameter_access" {
     resources = ["arn:aws:ssm:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:parameter/${var.env}/${var.project}/backend/*"]
   }
 }
+
+// backend can read its deployment metadata written by ci_lambda to report own version
+resource "aws_iam_role_policy_attachment" "backend_task_deployment_metadata" {
+  role       = aws_iam_role.backend_task.name
+  policy_arn = aws_iam_policy.backend_deployment_metadata.arn
+}
+
+resource "aws_iam_policy" "backend_deployment_metadata" {
+  name   = "BackendDeploymentMetadataPolicy"
+  policy = data.aws_iam_policy_document.backend_deployment_metadata.json
+}
+
+data "aws_iam_policy_document" "backend_deployment_metadata" {
+  statement {
+    actions   = ["ssm:GetParameter"]
+    resources = ["arn:aws:ssm:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:parameter/${var.env}/${var.project}/deployment/backend/metadata"]
+  }
+}
+
+// backend uses the environment key for the images bucket objects and the database password secret
+resource "aws_iam_role_policy_attachment" "backend_task_kms" {
+  count      = var.enable_kms ? 1 : 0
+  role       = aws_iam_role.backend_task.name
+  policy_arn = aws_iam_policy.backend_kms[0].arn
+}
+
+resource "aws_iam_role_policy_attachment" "backend_task_execution_kms" {
+  count      = var.enable_kms ? 1 : 0
+  role       = aws_iam_role.backend_task_execution.name
+  policy_arn = aws_iam_policy.backend_kms[0].arn
+}
+
+resource "aws_iam_policy" "backend_kms" {
+  count  = var.enable_kms ? 1 : 0
+  name   = "BackendKMSPolicy"
+  policy = data.aws_iam_policy_document.backend_kms.json
+}
+
+data "aws_iam_policy_document" "backend_kms" {
+  statement {
+    actions   = ["kms:Decrypt", "kms:GenerateDataKey"]
+    resources = [var.kms_key_arn]
+  }
+}
+
@@ -4,12 +4,23 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ecs"
 )
 
-func deploy(srv Service, serviceName string) (string, error) {
+// deploy updates the service with the latest task definition,
+// meta has the details known by the caller, e.g. the pushed image, and is completed and written to SSM after the update.
+func deploy(srv Service, serviceName string, meta DeploymentMetadata) (string, error) {
+	incident, err := getIncident(srv)
+	if err != nil {
+		return "", err
+	}
+	if incident != nil {
+		return queueDeployment(srv, serviceName, meta)
+	}
+
 	// Listing all task definitions with the specific family prefix
 	taskList, err := srv.ListTaskDefinitions(&ecs.ListTaskDefinitionsInput{
 		FamilyPrefix: &serviceName,
@@ -31,10 +42,21 @@ func deploy(srv Service, serviceName string) (string, error) {
 		return "", fmt.Errorf("unable to extract service name from arn: %s", latestTaskDefinition)
 	}
 	clusterName := fmt.Sprintf("%s_cluster_%s", ProjectName, Env)
+	meta.Service = serviceName
 	serviceName = fmt.Sprintf("%s_service_%s", serviceName, Env)
 
+	if err := validateTaskDefinitionSecrets(srv, latestTaskDefinition); err != nil {
+		if len(SlackWebhookURL) > 0 {
+			data := templateData{Env: Env, Service: serviceName, Reason: err.Error(), EventID: eventID}
+			if err := sendSlackMessage(errorTmpl, data); err != nil {
+				fmt.Printf("Unable to send slack message: %v.\n", err)
+			}
+		}
+		return "", fmt.Errorf("deployment of %s is cancelled: %v", serviceName, err)
+	}
+
 	// Updating the ECS service with the latest task definition revision
-	_, err = srv.UpdateService(&ecs.UpdateServiceInput{
+	updated, err := srv.UpdateService(&ecs.UpdateServiceInput{
 		Service:            &serviceName,
 		Cluster:            &clusterName,
 		TaskDefinition:     &latestTaskDefinition,
@@ -45,6 +67,20 @@ func deploy(srv Service, serviceName string) (string, error) {
 		return "", fmt.Errorf("unable to update ECS service: %v", err)
 	}
 
+	// ECS deployment events carry the deployment id only, log it to trace the deployment back to the event
+	if updated.Service != nil {
+		for _, d := range updated.Service.Deployments {
+			if aws.StringValue(d.Status) == "PRIMARY" {
+				fmt.Printf("Event %s started deployment %s of service %s.\n", eventID, aws.StringValue(d.Id), serviceName)
+			}
+		}
+	}
+
+	meta.TaskDefinition = latestTaskDefinition
+	meta.DeployedAt = time.Now().UTC()
+	meta.EventID = eventID
+	writeDeploymentMetadata(srv, meta)
+
 	result := fmt.Sprintf("Processed ECR event and updated ECS service: %s with the latest task definition %s", serviceName, latestTaskDefinition)
 	fmt.Println(result)
 
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"regexp"
 
 	"github.com/aws/aws-lambda-go/events"
 )
@@ -13,6 +12,7 @@ import (
 type ECRImagePushEventDetail struct {
 	RepositoryName string `json:"repository-name"`
 	Tag            string `json:"image-tag"`
+	Digest         string `json:"image-digest"`
 	Action         string `json:"action-type"`
 	Result         string `json:"result"`
 }
@@ -33,17 +33,20 @@ func processECREvent(srv Service, ctx context.Context, e events.CloudWatchEvent)
 		return fmt.Sprintf("Skipping event with result: %s", detail.Result), nil
 	}
 
+	if !isDeploymentAllowed(EventAttributes{Source: e.Source, DetailType: e.DetailType, Repository: detail.RepositoryName, Tag: detail.Tag}) {
+		return fmt.Sprintf("Skipping image %s:%s, it does not match event rules", detail.RepositoryName, detail.Tag), nil
+	}
+
 	serviceName, err := getServiceNameFromRepoName(detail.RepositoryName)
 	if err != nil {
 		return "", fmt.Errorf("unable to extract service name from repo name: %s", detail.RepositoryName)
 	}
 
-	return deploy(srv, serviceName)
+	return deploy(srv, serviceName, DeploymentMetadata{ImageTag: detail.Tag, ImageDigest: detail.Digest})
 }
 
 func getServiceNameFromRepoName(str string) (string, error) {
-	re := regexp.MustCompile(`\w+_(?P<service>\w+)`)
-	match := re.FindStringSubmatch(str)
+	match := repoServiceNameRe.FindStringSubmatch(str)
 	if len(match) == 2 {
 		return match[1], nil
 	}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
 )
@@ -49,17 +50,17 @@ const (
 )
 
 type templateData struct {
-	Env       string
-	Service   string
-	Reason    string
-	StateName string
+	Env          string
+	Service      string
+	Reason       string
+	StateName    string
+	StoppedTasks []string
+	EventID      string
+	DeploymentID string
+	Commit       *Commit // deployed commit, when the image tag has git sha and GitHub repository is configured
 }
 
 func processECSEvent(srv Service, ctx context.Context, e events.CloudWatchEvent) (string, error) {
-	if len(SlackWebhookURL) == 0 {
-		return "no webhook setup, ignoring service deployment event", nil
-	}
-
 	var detail ECSServiceDeployEvent
 	err := json.Unmarshal(e.Detail, &detail)
 	if err != nil {
@@ -72,50 +73,109 @@ func processECSEvent(srv Service, ctx context.Context, e events.CloudWatchEvent)
 	}
 	fmt.Printf("New ECS deployment event type: %s, with name: %s with resource: %s.\n", detail.EventType, detail.EventName, resource)
 
-	data := templateData{
-		Service:   resource,
-		Reason:    detail.Reason,
-		StateName: string(detail.EventName),
-		Env:       Env,
+	var meta *DeploymentMetadata
+	if detail.EventName == ECSEventNameCompleted && (len(GrafanaURL) > 0 || len(GitHubRepository) > 0) {
+		var taskDefinition string
+		taskDefinition, meta = findDeploymentMetadata(srv, resource, detail.DeploymentID)
+		// annotation is nice to have, don't fail the slack notification because of it
+		if err := annotateDeployment(resource, detail.DeploymentID, taskDefinition, meta, e.Time); err != nil {
+			fmt.Printf("Unable to add grafana annotation: %v.\n", err)
+		}
 	}
 
-	var payload bytes.Buffer
-	var t *template.Template
-	switch detail.EventName {
-	case ECSEventNameFailed:
-		t = errorTmpl
-	case ECSEventNameCompleted:
-		t = successTmpl
-	case ECSEventNameServiceSteady:
+	if len(SlackWebhookURL) == 0 && len(EmailRecipients) == 0 {
+		return "no webhook or email recipients setup, ignoring service deployment event", nil
+	}
+
+	if detail.EventName == ECSEventNameServiceSteady {
 		return "Ignoring SERVICE_STEADY_STATE, as it produces too much noise!", nil
-	default:
-		t = infoTmpl
 	}
 
+	data := templateData{
+		Service:      resource,
+		Reason:       detail.Reason,
+		StateName:    string(detail.EventName),
+		Env:          Env,
+		EventID:      eventID,
+		DeploymentID: detail.DeploymentID,
+		Commit:       getDeployedCommit(meta),
+	}
+
+	// circuit breaker or crash loop, explain what happened with the tasks
+	if detail.EventName == ECSEventNameFailed || detail.EventName == ECSEventNameServiceTaskImpaired {
+		data.StoppedTasks, err = getStoppedTasksReasons(srv, resource)
+		if err != nil {
+			fmt.Printf("Unable to get stopped tasks: %v.\n", err)
+		}
+	}
+
+	sent := []string{}
+	if len(EmailRecipients) > 0 && isEmailEvent(detail.EventName) {
+		// email is sent in addition to slack, don't fail the slack notification because of it
+		if err := sendEmailMessage(srv, data); err != nil {
+			fmt.Printf("Unable to send email message: %v.\n", err)
+		} else {
+			sent = append(sent, "email")
+		}
+	}
+
+	if len(SlackWebhookURL) > 0 {
+		var t *template.Template
+		switch detail.EventName {
+		case ECSEventNameFailed:
+			t = errorTmpl
+		case ECSEventNameCompleted:
+			t = successTmpl
+		default:
+			t = infoTmpl
+		}
+
+		// crash loop is reported as a warning, but needs attention as much as a failed deployment
+		isFailure := detail.EventName == ECSEventNameFailed || detail.EventName == ECSEventNameServiceTaskImpaired || detail.EventType == ECSEventTypeError
+		if !isFailure && isQuietTime(e.Time) {
+			fmt.Printf("Quiet hours, skipping slack message for %s and %s.\n", detail.EventType, detail.EventName)
+		} else {
+			if err := sendSlackMessage(t, data); err != nil {
+				return "", err
+			}
+			sent = append(sent, "slack")
+		}
+	}
+
+	if len(sent) == 0 {
+		result := fmt.Sprintf("no messages sent for %s and %s.", detail.EventType, detail.EventName)
+		fmt.Println(result)
+		return result, nil
+	}
+
+	result := fmt.Sprintf("sent %s message for %s and %s.", strings.Join(sent, " and "), detail.EventType, detail.EventName)
+	fmt.Println(result)
+
+	return result, nil
+}
+
+// sendSlackMessage renders the message template with data and posts it to slack webhook
+func sendSlackMessage(t *template.Template, data templateData) error {
+	var payload bytes.Buffer
 	if err := t.Execute(&payload, data); err != nil {
-		return "", err
+		return err
 	}
 
 	req, err := http.NewRequest(http.MethodPost, SlackWebhookURL, bytes.NewReader(payload.Bytes()))
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return "", fmt.Errorf("could not send slack message: %s", resp.Status)
+		return fmt.Errorf("could not send slack message: %s", resp.Status)
 	}
-
-	result := fmt.Sprintf("sent slack message for %s and %s.", detail.EventType, detail.EventName)
-	fmt.Println(result)
-
-	return result, nil
+	return nil
 }
 
 // Service steady state
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ses"
+)
+
+//go:embed email.message.txt.tmpl
+var emailText string
+var emailTmpl, _ = template.New("email").Parse(emailText)
+
+// isEmailEvent reports whether deployment event is worth an email: start, success or failure of the deployment
+func isEmailEvent(name ECSEventName) bool {
+	return name == ECSEventNameInProgress || name == ECSEventNameCompleted || name == ECSEventNameFailed
+}
+
+// sendEmailMessage sends deployment notification to EMAIL_RECIPIENTS with SES
+func sendEmailMessage(srv Service, data templateData) error {
+	var body bytes.Buffer
+	if err := emailTmpl.Execute(&body, data); err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("[%s] %s: %s", data.Env, getResourceNameFromARN(data.Service), data.StateName)
+	_, err := srv.SendEmail(&ses.SendEmailInput{
+		Source: &EmailSender,
+		Destination: &ses.Destination{
+			ToAddresses: aws.StringSlice(strings.Split(EmailRecipients, ",")),
+		},
+		Message: &ses.Message{
+			Subject: &ses.Content{Data: &subject},
+			Body: &ses.Body{
+				Text: &ses.Content{Data: aws.String(body.String())},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not send email: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// EventRule matches deployment events, every field is a regexp matched against the whole value, empty field matches anything.
+// e.g. [{"source":"aws.ecr","tag":"v\\d+\\.\\d+\\.\\d+"},{"source":"aws.ssm","parameter":".*/backend/.*"}]
+type EventRule struct {
+	Source     string `json:"source"`
+	DetailType string `json:"detail_type"`
+	Repository string `json:"repository"` // ECR repository name
+	Tag        string `json:"tag"`        // ECR image tag
+	Parameter  string `json:"parameter"`  // SSM parameter name
+}
+
+// EventAttributes are the event values the rules are matched against
+type EventAttributes struct {
+	Source     string
+	DetailType string
+	Repository string
+	Tag        string
+	Parameter  string
+}
+
+type eventMatcher []*regexp.Regexp
+
+func parseEventRules(rules string) ([]eventMatcher, error) {
+	var parsed []EventRule
+	if err := json.Unmarshal([]byte(rules), &parsed); err != nil {
+		return nil, fmt.Errorf("could not unmarshal event rules: %v", err)
+	}
+
+	matchers := []eventMatcher{}
+	for _, r := range parsed {
+		m := eventMatcher{}
+		for _, pattern := range []string{r.Source, r.DetailType, r.Repository, r.Tag, r.Parameter} {
+			if len(pattern) == 0 {
+				pattern = ".*"
+			}
+			re, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid event rule pattern %s: %v", pattern, err)
+			}
+			m = append(m, re)
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+func (m eventMatcher) Match(a EventAttributes) bool {
+	for i, value := range []string{a.Source, a.DetailType, a.Repository, a.Tag, a.Parameter} {
+		if !m[i].MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	eventRulesParsed   bool
+	eventRulesSource   string
+	eventRulesMatchers []eventMatcher
+	eventRulesErr      error
+)
+
+// eventRuleMatchers parses EVENT_RULES once and keeps the result for warm invocations
+func eventRuleMatchers() ([]eventMatcher, error) {
+	if !eventRulesParsed || eventRulesSource != EventRules {
+		eventRulesMatchers, eventRulesErr = parseEventRules(EventRules)
+		eventRulesSource, eventRulesParsed = EventRules, true
+	}
+	return eventRulesMatchers, eventRulesErr
+}
+
+// isDeploymentAllowed reports whether the event should trigger a deployment,
+// any event is allowed when EVENT_RULES is not set, otherwise it should match at least one rule.
+// Invalid rules block every event, as they are meant to stop unwanted deployments.
+func isDeploymentAllowed(a EventAttributes) bool {
+	if len(EventRules) == 0 {
+		return true
+	}
+
+	matchers, err := eventRuleMatchers()
+	if err != nil {
+		fmt.Printf("Blocking event, invalid event rules: %v.\n", err)
+		return false
+	}
+	for _, m := range matchers {
+		if m.Match(a) {
+			return true
+		}
+	}
+	return false
+}
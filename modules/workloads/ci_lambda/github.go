@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// githubAPIURL is a variable to point tests to the local server
+var githubAPIURL = "https://api.github.com"
+
+// Commit is the deployed commit shown in slack notification
+type Commit struct {
+	SHA        string
+	Message    string // first line of the commit message
+	Author     string
+	URL        string
+	CompareURL string // changes since the previously deployed commit
+}
+
+// SlackLink is the commit link in slack mrkdwn format, html template would escape angle brackets of the link.
+// The link is built from the repository and git sha only, so it is safe to keep as is.
+func (c *Commit) SlackLink() template.HTML {
+	return template.HTML(fmt.Sprintf("<%s|%s>", c.URL, c.SHA))
+}
+
+func (c *Commit) SlackCompareLink() template.HTML {
+	return template.HTML(fmt.Sprintf("<%s|Changes since the previous deployment>", c.CompareURL))
+}
+
+// SlackMessage and SlackAuthor are the commit details escaped for slack mrkdwn inside JSON string,
+// html template would turn the quotes into HTML entities, which slack shows as is.
+func (c *Commit) SlackMessage() template.HTML {
+	return slackText(c.Message)
+}
+
+func (c *Commit) SlackAuthor() template.HTML {
+	return slackText(c.Author)
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func slackText(s string) template.HTML {
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	// encoding a string never fails
+	_ = encoder.Encode(slackEscaper.Replace(s))
+	quoted := strings.TrimSuffix(b.String(), "\n")
+	return template.HTML(quoted[1 : len(quoted)-1])
+}
+
+// GitHubCommit is the part of GitHub commits API response we need.
+// https://docs.github.com/en/rest/commits/commits#get-a-commit
+type GitHubCommit struct {
+	Commit struct {
+		Message string `json:"message"`
+		Author  struct {
+			Name string `json:"name"`
+		} `json:"author"`
+	} `json:"commit"`
+}
+
+// getDeployedCommit returns the commit of the deployed image, does nothing if GitHub repository is not configured,
+// or the image tag doesn't have git sha. Commit message and author are nice to have, so GitHub API errors are logged only.
+func getDeployedCommit(meta *DeploymentMetadata) *Commit {
+	if len(GitHubRepository) == 0 || meta == nil || len(meta.GitSHA) == 0 {
+		return nil
+	}
+
+	commit := &Commit{
+		SHA: meta.GitSHA,
+		URL: fmt.Sprintf("https://github.com/%s/commit/%s", GitHubRepository, meta.GitSHA),
+	}
+	if len(meta.PreviousGitSHA) > 0 {
+		commit.CompareURL = fmt.Sprintf("https://github.com/%s/compare/%s...%s", GitHubRepository, meta.PreviousGitSHA, meta.GitSHA)
+	}
+
+	details, err := getGitHubCommit(meta.GitSHA)
+	if err != nil {
+		fmt.Printf("Unable to get commit %s from GitHub: %v.\n", meta.GitSHA, err)
+		return commit
+	}
+
+	message, _, _ := strings.Cut(details.Commit.Message, "\n")
+	commit.Message = message
+	commit.Author = details.Commit.Author.Name
+	return commit
+}
+
+func getGitHubCommit(sha string) (*GitHubCommit, error) {
+	url := fmt.Sprintf("%s/repos/%s/commits/%s", strings.TrimSuffix(githubAPIURL, "/"), GitHubRepository, sha)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	// token is required for private repositories only
+	if len(GitHubToken) > 0 {
+		req.Header.Set("Authorization", "Bearer "+GitHubToken)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("could not get commit: %s", resp.Status)
+	}
+
+	var commit GitHubCommit
+	if err := json.NewDecoder(resp.Body).Decode(&commit); err != nil {
+		return nil, fmt.Errorf("could not decode commit: %v", err)
+	}
+	return &commit, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GrafanaAnnotation is the request body of Grafana annotations API.
+// https://grafana.com/docs/grafana/latest/developers/http_api/annotations/
+type GrafanaAnnotation struct {
+	Time int64    `json:"time"` // epoch in milliseconds
+	Tags []string `json:"tags"`
+	Text string   `json:"text"`
+}
+
+// annotateDeployment adds a deployment marker to Grafana dashboards, does nothing if Grafana is not configured.
+// taskDefinition and meta describe the deployed version, when they are known.
+func annotateDeployment(serviceARN, deploymentID, taskDefinition string, meta *DeploymentMetadata, t time.Time) error {
+	if len(GrafanaURL) == 0 {
+		return nil
+	}
+
+	service := getResourceNameFromARN(serviceARN)
+	text := fmt.Sprintf("[%s]: Service %s deployed", Env, service)
+	if len(taskDefinition) > 0 {
+		text += " " + getResourceNameFromARN(taskDefinition)
+	}
+	if meta != nil && len(meta.ImageTag) > 0 {
+		text += fmt.Sprintf(" image %s", meta.ImageTag)
+	}
+	if meta != nil && len(meta.GitSHA) > 0 {
+		text += fmt.Sprintf(" commit %s", meta.GitSHA)
+	}
+	text += fmt.Sprintf(" (%s) by ci_lambda", deploymentID)
+	if meta != nil {
+		text += fmt.Sprintf(", started by event %s", meta.EventID)
+	}
+
+	annotation := GrafanaAnnotation{
+		Time: t.UnixMilli(),
+		Tags: []string{"deployment", Env, service},
+		Text: text,
+	}
+	payload, err := json.Marshal(annotation)
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(GrafanaURL, "/") + "/api/annotations"
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+GrafanaAPIKey)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("could not add grafana annotation: %s", resp.Status)
+	}
+
+	fmt.Printf("Added grafana annotation for service %s deployment %s.\n", service, deploymentID)
+	return nil
+}
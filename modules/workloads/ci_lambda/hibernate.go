@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+type HibernateEventDetail struct {
+	Action HibernateAction `json:"action"`
+}
+
+type HibernateAction string
+
+const (
+	HibernateActionSleep HibernateAction = "sleep" // scale all services to zero and stop the database
+	HibernateActionWake  HibernateAction = "wake"  // bring back everything stopped by sleep
+)
+
+// RDSEventDetail is the part of RDS DB instance event we need
+type RDSEventDetail struct {
+	EventID          string `json:"EventID"`
+	SourceIdentifier string `json:"SourceIdentifier"`
+}
+
+// rdsEventInstanceStarted is sent when the stopped DB instance is started and available
+const rdsEventInstanceStarted = "RDS-EVENT-0088"
+
+// hibernateParameterName keeps the service desired count while it is scaled to zero.
+// It is outside of the service parameters path, so it doesn't trigger SSM deployments,
+// and unlike service tags it is not reset by terraform apply.
+func hibernateParameterName(service string) string {
+	return fmt.Sprintf("/%s/%s/hibernate/%s/desired_count", Env, ProjectName, service)
+}
+
+func processHibernateEvent(srv Service, ctx context.Context, e events.CloudWatchEvent) (string, error) {
+	var detail HibernateEventDetail
+	err := json.Unmarshal(e.Detail, &detail)
+	if err != nil {
+		return "", fmt.Errorf("could not unmarshal event detail: %v", err)
+	}
+	fmt.Printf("New hibernate command %s.\n", detail.Action)
+
+	switch detail.Action {
+	case HibernateActionSleep:
+		// services need the database, so it stops after them
+		result, err := hibernateServices(srv, detail.Action)
+		if err != nil {
+			return "", err
+		}
+		stopDatabase(srv)
+		return result, nil
+	case HibernateActionWake:
+		// services are woken up when the database is available, on its started event
+		if !startDatabase(srv) {
+			result := fmt.Sprintf("Starting database %s, services are woken up when it is available", HibernateDBIdentifier)
+			fmt.Println(result)
+			return result, nil
+		}
+		return hibernateServices(srv, detail.Action)
+	}
+	return "", fmt.Errorf("unsupported hibernate action: %s", detail.Action)
+}
+
+// processRDSEvent wakes up the services when the hibernated database is started
+func processRDSEvent(srv Service, ctx context.Context, e events.CloudWatchEvent) (string, error) {
+	var detail RDSEventDetail
+	err := json.Unmarshal(e.Detail, &detail)
+	if err != nil {
+		return "", fmt.Errorf("could not unmarshal event detail: %v", err)
+	}
+	fmt.Printf("New RDS event %s for %s.\n", detail.EventID, detail.SourceIdentifier)
+
+	if len(HibernateDBIdentifier) == 0 || detail.SourceIdentifier != HibernateDBIdentifier || detail.EventID != rdsEventInstanceStarted {
+		return fmt.Sprintf("Skipping RDS event %s for %s", detail.EventID, detail.SourceIdentifier), nil
+	}
+	return hibernateServices(srv, HibernateActionWake)
+}
+
+// hibernateServices scales all services of the cluster to zero, or brings back the services scaled down by sleep
+func hibernateServices(srv Service, action HibernateAction) (string, error) {
+	clusterName := fmt.Sprintf("%s_cluster_%s", ProjectName, Env)
+	services, err := describeClusterServices(srv, clusterName)
+	if err != nil {
+		return "", err
+	}
+
+	changed := []string{}
+	for _, s := range services {
+		var updated bool
+		if action == HibernateActionSleep {
+			updated, err = sleepService(srv, clusterName, s)
+		} else {
+			updated, err = wakeService(srv, clusterName, s)
+		}
+		if err != nil {
+			return "", err
+		}
+		if updated {
+			changed = append(changed, aws.StringValue(s.ServiceName))
+		}
+	}
+
+	result := fmt.Sprintf("Processed hibernate %s for services: %s", action, strings.Join(changed, ", "))
+	fmt.Println(result)
+
+	return result, nil
+}
+
+func describeClusterServices(srv Service, clusterName string) ([]*ecs.Service, error) {
+	services := []*ecs.Service{}
+	var nextToken *string
+	for {
+		// describe services accepts up to 10 services at once
+		list, err := srv.ListServices(&ecs.ListServicesInput{
+			Cluster:    &clusterName,
+			MaxResults: aws.Int64(10),
+			NextToken:  nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list services: %v", err)
+		}
+
+		if len(list.ServiceArns) > 0 {
+			described, err := srv.DescribeServices(&ecs.DescribeServicesInput{
+				Cluster:  &clusterName,
+				Services: list.ServiceArns,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("unable to describe services: %v", err)
+			}
+			services = append(services, described.Services...)
+		}
+
+		if list.NextToken == nil {
+			return services, nil
+		}
+		nextToken = list.NextToken
+	}
+}
+
+func sleepService(srv Service, clusterName string, s *ecs.Service) (bool, error) {
+	desiredCount := aws.Int64Value(s.DesiredCount)
+	if desiredCount == 0 {
+		return false, nil
+	}
+
+	_, err := srv.PutParameter(&ssm.PutParameterInput{
+		Name:      aws.String(hibernateParameterName(aws.StringValue(s.ServiceName))),
+		Type:      aws.String(ssm.ParameterTypeString),
+		Value:     aws.String(strconv.FormatInt(desiredCount, 10)),
+		Overwrite: aws.Bool(true),
+	})
+	if err != nil {
+		return false, fmt.Errorf("unable to save desired count of ECS service: %v", err)
+	}
+
+	// autoscaling would bring the tasks back up to its min capacity
+	err = suspendServiceScaling(srv, clusterName, s, true)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = srv.UpdateService(&ecs.UpdateServiceInput{
+		Service:      s.ServiceName,
+		Cluster:      &clusterName,
+		DesiredCount: aws.Int64(0),
+	})
+	if err != nil {
+		return false, fmt.Errorf("unable to update ECS service: %v", err)
+	}
+	return true, nil
+}
+
+func wakeService(srv Service, clusterName string, s *ecs.Service) (bool, error) {
+	name := hibernateParameterName(aws.StringValue(s.ServiceName))
+	param, err := srv.GetParameter(&ssm.GetParameterInput{Name: &name})
+	// service has not been scaled down by sleep
+	if isAWSErrorCode(err, ssm.ErrCodeParameterNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to get saved desired count of ECS service: %v", err)
+	}
+	desiredCount, err := strconv.ParseInt(aws.StringValue(param.Parameter.Value), 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid saved desired count of service %s: %v", aws.StringValue(s.ServiceName), err)
+	}
+
+	_, err = srv.UpdateService(&ecs.UpdateServiceInput{
+		Service:      s.ServiceName,
+		Cluster:      &clusterName,
+		DesiredCount: &desiredCount,
+	})
+	if err != nil {
+		return false, fmt.Errorf("unable to update ECS service: %v", err)
+	}
+
+	err = suspendServiceScaling(srv, clusterName, s, false)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = srv.DeleteParameter(&ssm.DeleteParameterInput{Name: &name})
+	if err != nil && !isAWSErrorCode(err, ssm.ErrCodeParameterNotFound) {
+		return false, fmt.Errorf("unable to delete saved desired count of ECS service: %v", err)
+	}
+	return true, nil
+}
+
+// suspendServiceScaling suspends or resumes all scaling activities of the service autoscaling target.
+// Services without autoscaling are skipped.
+func suspendServiceScaling(srv Service, clusterName string, s *ecs.Service, suspended bool) error {
+	resourceID := fmt.Sprintf("service/%s/%s", clusterName, aws.StringValue(s.ServiceName))
+	targets, err := srv.DescribeScalableTargets(&applicationautoscaling.DescribeScalableTargetsInput{
+		ServiceNamespace:  aws.String(applicationautoscaling.ServiceNamespaceEcs),
+		ScalableDimension: aws.String(applicationautoscaling.ScalableDimensionEcsServiceDesiredCount),
+		ResourceIds:       []*string{&resourceID},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to describe scalable targets: %v", err)
+	}
+	if len(targets.ScalableTargets) == 0 {
+		return nil
+	}
+
+	_, err = srv.RegisterScalableTarget(&applicationautoscaling.RegisterScalableTargetInput{
+		ServiceNamespace:  aws.String(applicationautoscaling.ServiceNamespaceEcs),
+		ScalableDimension: aws.String(applicationautoscaling.ScalableDimensionEcsServiceDesiredCount),
+		ResourceId:        &resourceID,
+		SuspendedState: &applicationautoscaling.SuspendedState{
+			DynamicScalingInSuspended:  aws.Bool(suspended),
+			DynamicScalingOutSuspended: aws.Bool(suspended),
+			ScheduledScalingSuspended:  aws.Bool(suspended),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to update scalable target %s: %v", resourceID, err)
+	}
+	return nil
+}
+
+// startDatabase starts the stopped database, if it is configured, and reports whether it is available already.
+// The database could be started or stopped manually, so errors are logged only and services are woken up anyway.
+func startDatabase(srv Service) bool {
+	if len(HibernateDBIdentifier) == 0 {
+		return true
+	}
+
+	described, err := srv.DescribeDBInstances(&rds.DescribeDBInstancesInput{DBInstanceIdentifier: &HibernateDBIdentifier})
+	if err != nil || len(described.DBInstances) == 0 {
+		fmt.Printf("Unable to describe database %s: %v.\n", HibernateDBIdentifier, err)
+		return true
+	}
+
+	switch aws.StringValue(described.DBInstances[0].DBInstanceStatus) {
+	case "stopped":
+		_, err = srv.StartDBInstance(&rds.StartDBInstanceInput{DBInstanceIdentifier: &HibernateDBIdentifier})
+		if err != nil {
+			fmt.Printf("Unable to start database %s: %v.\n", HibernateDBIdentifier, err)
+			return true
+		}
+		return false
+	case "starting":
+		return false
+	}
+	return true
+}
+
+// stopDatabase stops the database, if it is configured.
+// The database could be already stopped manually, so errors are logged only.
+func stopDatabase(srv Service) {
+	if len(HibernateDBIdentifier) == 0 {
+		return
+	}
+
+	_, err := srv.StopDBInstance(&rds.StopDBInstanceInput{DBInstanceIdentifier: &HibernateDBIdentifier})
+	if err != nil {
+		fmt.Printf("Unable to stop database %s: %v.\n", HibernateDBIdentifier, err)
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+//go:embed slack.message.incident.json.tmpl
+var incidentJson string
+var incidentTmpl, _ = template.New("incident").Parse(incidentJson)
+
+type IncidentEventDetail struct {
+	Action IncidentAction `json:"action"`
+	Reason string         `json:"reason"`
+}
+
+type IncidentAction string
+
+const (
+	IncidentActionStart IncidentAction = "start" // freeze deployments, they are queued until the incident is over
+	IncidentActionEnd   IncidentAction = "end"   // release queued deployments in order
+)
+
+// Incident is stored as JSON in SSM parameter while the incident is active
+type Incident struct {
+	Reason string `json:"reason"`
+}
+
+// QueuedDeployment is stored as JSON in SSM parameter per service queued during the incident,
+// every service has its own parameter, so concurrent invocations don't overwrite each other's queue
+type QueuedDeployment struct {
+	QueuedAt time.Time          `json:"queued_at"`
+	Metadata DeploymentMetadata `json:"metadata"`
+}
+
+// incidentParameterName is outside of the service parameters path, so it doesn't trigger SSM deployments
+func incidentParameterName() string {
+	return fmt.Sprintf("/%s/%s/incident", Env, ProjectName)
+}
+
+func incidentQueuePath() string {
+	return incidentParameterName() + "/queue"
+}
+
+func processIncidentEvent(srv Service, ctx context.Context, e events.CloudWatchEvent) (string, error) {
+	var detail IncidentEventDetail
+	err := json.Unmarshal(e.Detail, &detail)
+	if err != nil {
+		return "", fmt.Errorf("could not unmarshal event detail: %v", err)
+	}
+	fmt.Printf("New incident command %s.\n", detail.Action)
+
+	switch detail.Action {
+	case IncidentActionStart:
+		return startIncident(srv, detail.Reason)
+	case IncidentActionEnd:
+		return endIncident(srv)
+	}
+	return "", fmt.Errorf("unsupported incident action: %s", detail.Action)
+}
+
+func startIncident(srv Service, reason string) (string, error) {
+	value, err := json.Marshal(Incident{Reason: reason})
+	if err != nil {
+		return "", err
+	}
+
+	// the incident parameter is created only once, so the concurrent start doesn't overwrite the active incident
+	_, err = srv.PutParameter(&ssm.PutParameterInput{
+		Name:      aws.String(incidentParameterName()),
+		Type:      aws.String(ssm.ParameterTypeString),
+		Value:     aws.String(string(value)),
+		Overwrite: aws.Bool(false),
+	})
+	if isAWSErrorCode(err, ssm.ErrCodeParameterAlreadyExists) {
+		incident, err := getIncident(srv)
+		if err != nil {
+			return "", err
+		}
+		if incident != nil {
+			return fmt.Sprintf("Incident is already active: %s", incident.Reason), nil
+		}
+		return "", fmt.Errorf("incident has ended while starting a new one, try again")
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to put incident parameter: %v", err)
+	}
+	notifyIncident("started", fmt.Sprintf("%s. Deployments are queued until the incident is over.", reason))
+
+	result := fmt.Sprintf("Incident started, deployments are frozen: %s", reason)
+	fmt.Println(result)
+
+	return result, nil
+}
+
+func endIncident(srv Service) (string, error) {
+	incident, err := getIncident(srv)
+	if err != nil {
+		return "", err
+	}
+
+	// the incident is deleted before the queue is read, deployments queued after that release themselves
+	if incident != nil {
+		name := incidentParameterName()
+		_, err = srv.DeleteParameter(&ssm.DeleteParameterInput{Name: &name})
+		if err != nil && !isAWSErrorCode(err, ssm.ErrCodeParameterNotFound) {
+			return "", fmt.Errorf("unable to delete incident parameter: %v", err)
+		}
+	}
+
+	// the queue is released even when the incident is gone, so the retried end deploys what the failed one has left
+	queue, err := getQueuedDeployments(srv)
+	if err != nil {
+		return "", err
+	}
+	if incident == nil && len(queue) == 0 {
+		return "No active incident, nothing to end", nil
+	}
+
+	// keep releasing the queue, a failed deployment should not block the rest
+	released, failed := []string{}, []string{}
+	for _, q := range queue {
+		ok, err := releaseQueuedDeployment(srv, q)
+		if err != nil {
+			fmt.Printf("Unable to deploy queued service %s: %v.\n", q.Metadata.Service, err)
+			failed = append(failed, q.Metadata.Service)
+		} else if ok {
+			released = append(released, q.Metadata.Service)
+		}
+	}
+
+	reason := "Deployments are unfrozen."
+	if len(released) > 0 {
+		reason += fmt.Sprintf(" Released queued deployments: %s.", strings.Join(released, ", "))
+	}
+	notifyIncident("ended", reason)
+
+	if len(failed) > 0 {
+		return "", fmt.Errorf("incident ended, unable to deploy queued services: %s", strings.Join(failed, ", "))
+	}
+
+	result := fmt.Sprintf("Incident ended, released queued deployments: %s", strings.Join(released, ", "))
+	fmt.Println(result)
+
+	return result, nil
+}
+
+// queueDeployment postpones the service deployment until the incident is over
+func queueDeployment(srv Service, serviceName string, meta DeploymentMetadata) (string, error) {
+	meta.Service = serviceName
+	q := QueuedDeployment{QueuedAt: time.Now().UTC(), Metadata: meta}
+	value, err := json.Marshal(q)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s/%s", incidentQueuePath(), serviceName)
+	input := &ssm.PutParameterInput{
+		Name:      &name,
+		Type:      aws.String(ssm.ParameterTypeString),
+		Value:     aws.String(string(value)),
+		Overwrite: aws.Bool(false),
+	}
+	_, err = srv.PutParameter(input)
+	queued := isAWSErrorCode(err, ssm.ErrCodeParameterAlreadyExists)
+	if queued {
+		// the latest task definition is deployed anyway, so the service is queued once with the details of the latest event
+		input.Overwrite = aws.Bool(true)
+		_, err = srv.PutParameter(input)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to put queued deployment parameter: %v", err)
+	}
+
+	// the incident could end while the deployment was queued, after its queue had been released
+	incident, err := getIncident(srv)
+	if err != nil {
+		return "", err
+	}
+	if incident == nil {
+		if _, err := releaseQueuedDeployment(srv, q); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Incident has ended, deployment of %s is released", serviceName), nil
+	}
+
+	result := fmt.Sprintf("Deployment of %s is queued until the incident is over", serviceName)
+	if queued {
+		result = fmt.Sprintf("Deployment of %s is already queued until the incident is over", serviceName)
+	}
+	fmt.Println(result)
+
+	return result, nil
+}
+
+// releaseQueuedDeployment removes the service from the queue and deploys it.
+// The queue parameter is deleted first, so only one invocation deploys it, false is returned when it is already released.
+// When the deployment fails, the service is put back to the queue to be released by the next incident end.
+func releaseQueuedDeployment(srv Service, q QueuedDeployment) (bool, error) {
+	name := fmt.Sprintf("%s/%s", incidentQueuePath(), q.Metadata.Service)
+	_, err := srv.DeleteParameter(&ssm.DeleteParameterInput{Name: &name})
+	if isAWSErrorCode(err, ssm.ErrCodeParameterNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to delete queued deployment parameter: %v", err)
+	}
+
+	if _, err := deploy(srv, q.Metadata.Service, q.Metadata); err != nil {
+		if err := restoreQueuedDeployment(srv, name, q); err != nil {
+			fmt.Printf("Unable to put queued deployment of %s back: %v.\n", q.Metadata.Service, err)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// restoreQueuedDeployment doesn't overwrite the parameter, the service could be queued again with the newer details meanwhile
+func restoreQueuedDeployment(srv Service, name string, q QueuedDeployment) error {
+	value, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	_, err = srv.PutParameter(&ssm.PutParameterInput{
+		Name:      &name,
+		Type:      aws.String(ssm.ParameterTypeString),
+		Value:     aws.String(string(value)),
+		Overwrite: aws.Bool(false),
+	})
+	if isAWSErrorCode(err, ssm.ErrCodeParameterAlreadyExists) {
+		return nil
+	}
+	return err
+}
+
+// getQueuedDeployments returns the services queued during the incident in the order they were queued
+func getQueuedDeployments(srv Service) ([]QueuedDeployment, error) {
+	queue := []QueuedDeployment{}
+	var nextToken *string
+	for {
+		params, err := srv.GetParametersByPath(&ssm.GetParametersByPathInput{
+			Path:      aws.String(incidentQueuePath()),
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get queued deployments: %v", err)
+		}
+
+		for _, p := range params.Parameters {
+			var q QueuedDeployment
+			if err := json.Unmarshal([]byte(aws.StringValue(p.Value)), &q); err != nil {
+				return nil, fmt.Errorf("could not unmarshal queued deployment %s: %v", aws.StringValue(p.Name), err)
+			}
+			queue = append(queue, q)
+		}
+
+		if params.NextToken == nil {
+			break
+		}
+		nextToken = params.NextToken
+	}
+
+	sort.SliceStable(queue, func(i, j int) bool {
+		return queue[i].QueuedAt.Before(queue[j].QueuedAt)
+	})
+	return queue, nil
+}
+
+// getIncident returns the active incident, or nil when there is none
+func getIncident(srv Service) (*Incident, error) {
+	name := incidentParameterName()
+	param, err := srv.GetParameter(&ssm.GetParameterInput{Name: &name})
+	if err != nil {
+		if isAWSErrorCode(err, ssm.ErrCodeParameterNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to get incident parameter: %v", err)
+	}
+
+	var incident Incident
+	if err := json.Unmarshal([]byte(aws.StringValue(param.Parameter.Value)), &incident); err != nil {
+		return nil, fmt.Errorf("could not unmarshal incident parameter: %v", err)
+	}
+	return &incident, nil
+}
+
+func isAWSErrorCode(err error, code string) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == code
+}
+
+// notifyIncident posts the incident banner to slack, the incident state is already changed, so errors are logged only
+func notifyIncident(state, reason string) {
+	if len(SlackWebhookURL) == 0 {
+		return
+	}
+	data := templateData{Env: Env, StateName: state, Reason: reason, EventID: eventID}
+	if err := sendSlackMessage(incidentTmpl, data); err != nil {
+		fmt.Printf("Unable to send slack message: %v.\n", err)
+	}
+}
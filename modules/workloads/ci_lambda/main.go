@@ -4,22 +4,53 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 )
 
 var (
-	ProjectName     = os.Getenv("PROJECT_NAME")
-	SlackWebhookURL = os.Getenv("SLACK_WEBHOOK_URL")
-	Env             = os.Getenv("PROJECT_ENV")
+	ProjectName             = os.Getenv("PROJECT_NAME")
+	SlackWebhookURL         = os.Getenv("SLACK_WEBHOOK_URL")
+	SlackQuietHours         = os.Getenv("SLACK_QUIET_HOURS")
+	SlackQuietHoursTimezone = os.Getenv("SLACK_QUIET_HOURS_TIMEZONE")
+	Env                     = os.Getenv("PROJECT_ENV")
+	GrafanaURL              = os.Getenv("GRAFANA_URL")
+	GrafanaAPIKey           = os.Getenv("GRAFANA_API_KEY")
+	HibernateDBIdentifier   = os.Getenv("HIBERNATE_DB_IDENTIFIER")
+	EmailSender             = os.Getenv("EMAIL_SENDER")
+	EmailRecipients         = os.Getenv("EMAIL_RECIPIENTS")  // comma separated list
+	EventRules              = os.Getenv("EVENT_RULES")       // JSON list of EventRule
+	GitHubRepository        = os.Getenv("GITHUB_REPOSITORY") // owner/repo of the service images, to link deployments to commits
+	GitHubToken             = os.Getenv("GITHUB_TOKEN")
 )
 
+// Warm lambda instance reuses package state between invocations,
+// so regexps are compiled and HTTP connections are kept alive once per instance.
+var (
+	imageServiceNameRe = regexp.MustCompile(`\d{12}\.dkr\.ecr\.(\w|-)+\.amazonaws.com\/\w+_(?P<service>\w+)`)
+	repoServiceNameRe  = regexp.MustCompile(`\w+_(?P<service>\w+)`)
+	httpClient         = &http.Client{Timeout: 10 * time.Second}
+)
+
+// eventID is the id of the event being processed, to correlate logs and notifications of the same event.
+// Lambda instance processes one event at a time, so it is set on every invocation.
+var eventID string
+
 func Handler(srv Service) func(ctx context.Context, e events.CloudWatchEvent) (string, error) {
 	return func(ctx context.Context, e events.CloudWatchEvent) (string, error) {
-		fmt.Printf("Processing request data for event %s.\n", e.ID)
+		eventID = e.ID
+		requestID := ""
+		if lc, ok := lambdacontext.FromContext(ctx); ok {
+			requestID = lc.AwsRequestID
+		}
+		fmt.Printf("Processing request data for event %s, request %s.\n", e.ID, requestID)
 
 		switch e.Source {
 		case "aws.ecr":
@@ -30,6 +61,12 @@ func Handler(srv Service) func(ctx context.Context, e events.CloudWatchEvent) (s
 			return processProductionDeployEvent(srv, ctx, e)
 		case "aws.ssm":
 			return processSSMEvent(srv, ctx, e)
+		case "action.hibernate":
+			return processHibernateEvent(srv, ctx, e)
+		case "aws.rds":
+			return processRDSEvent(srv, ctx, e)
+		case "action.incident":
+			return processIncidentEvent(srv, ctx, e)
 		}
 
 		return "", fmt.Errorf("unable to process event: %s, unsupported event source: %s", e.ID, e.Source)
@@ -37,14 +74,19 @@ func Handler(srv Service) func(ctx context.Context, e events.CloudWatchEvent) (s
 }
 
 func getServiceName(str string) (string, error) {
-	re := regexp.MustCompile(`\d{12}\.dkr\.ecr\.(\w|-)+\.amazonaws.com\/\w+_(?P<service>\w+)`)
-	match := re.FindStringSubmatch(str)
+	match := imageServiceNameRe.FindStringSubmatch(str)
 	if len(match) == 3 {
 		return match[2], nil
 	}
 	return "", errors.New("Unable to extract service name")
 }
 
+// getResourceNameFromARN returns the last segment of resource arn,
+// e.g. arn:aws:ecs:us-west-2:111122223333:service/default/servicetest -> servicetest
+func getResourceNameFromARN(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
+
 func main() {
 	lambda.Start(Handler(NewAWSService()))
 }
@@ -3,16 +3,35 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
 	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/stretchr/testify/assert"
 )
 
 type MockService struct {
-	usi *ecs.UpdateServiceInput
+	usi      *ecs.UpdateServiceInput
+	lti      *ecs.ListTasksInput
+	services []*ecs.Service
+	db       string
+	missing  []string
+	sei      *ses.SendEmailInput
+	sesErr   error
+	params   map[string]string
+	targets  []*applicationautoscaling.ScalableTarget
+	rsti     *applicationautoscaling.RegisterScalableTargetInput
 }
 
 func (s *MockService) ListTaskDefinitions(input *ecs.ListTaskDefinitionsInput) (*ecs.ListTaskDefinitionsOutput, error) {
@@ -28,8 +47,140 @@ func (s *MockService) UpdateService(input *ecs.UpdateServiceInput) (*ecs.UpdateS
 	return &ecs.UpdateServiceOutput{}, nil
 }
 
+func (s *MockService) ListTasks(input *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	s.lti = input
+	return &ecs.ListTasksOutput{
+		TaskArns: []*string{
+			aws.String("arn:aws:ecs:us-west-2:111122223333:task/default/0123456789abcdef"),
+		},
+	}, nil
+}
+
+func (s *MockService) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	return &ecs.DescribeTasksOutput{
+		Tasks: []*ecs.Task{{
+			TaskArn:       aws.String("arn:aws:ecs:us-west-2:111122223333:task/default/0123456789abcdef"),
+			StoppedReason: aws.String("Essential container in task exited"),
+			Containers: []*ecs.Container{{
+				Name:     aws.String("chubby_backend_dev"),
+				ExitCode: aws.Int64(137),
+				Reason:   aws.String("OutOfMemoryError: Container killed due to memory usage"),
+			}},
+		}},
+	}, nil
+}
+
+func (s *MockService) ListServices(input *ecs.ListServicesInput) (*ecs.ListServicesOutput, error) {
+	arns := []*string{}
+	for _, srv := range s.services {
+		arns = append(arns, srv.ServiceArn)
+	}
+	return &ecs.ListServicesOutput{ServiceArns: arns}, nil
+}
+
+func (s *MockService) DescribeServices(input *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	return &ecs.DescribeServicesOutput{Services: s.services}, nil
+}
+
+func (s *MockService) StopDBInstance(input *rds.StopDBInstanceInput) (*rds.StopDBInstanceOutput, error) {
+	s.db = "stopped " + *input.DBInstanceIdentifier
+	return &rds.StopDBInstanceOutput{}, nil
+}
+
+func (s *MockService) StartDBInstance(input *rds.StartDBInstanceInput) (*rds.StartDBInstanceOutput, error) {
+	s.db = "started " + *input.DBInstanceIdentifier
+	return &rds.StartDBInstanceOutput{}, nil
+}
+
+func (s *MockService) DescribeDBInstances(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+	status := "available"
+	if strings.HasPrefix(s.db, "stopped ") {
+		status = "stopped"
+	} else if strings.HasPrefix(s.db, "started ") {
+		status = "starting"
+	}
+	return &rds.DescribeDBInstancesOutput{DBInstances: []*rds.DBInstance{{
+		DBInstanceIdentifier: input.DBInstanceIdentifier,
+		DBInstanceStatus:     &status,
+	}}}, nil
+}
+
+func (s *MockService) DescribeTaskDefinition(input *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	return &ecs.DescribeTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			TaskDefinitionArn: input.TaskDefinition,
+			ContainerDefinitions: []*ecs.ContainerDefinition{{
+				Name: aws.String("chubby_backend_dev"),
+				Secrets: []*ecs.Secret{
+					{Name: aws.String("ENV"), ValueFrom: aws.String("/dev/chubby/backend/env")},
+					{Name: aws.String("PG_DATABASE_PASSWORD"), ValueFrom: aws.String("/dev/chubby/backend/pg_database_password")},
+				},
+			}},
+		},
+	}, nil
+}
+
+func (s *MockService) GetParameters(input *ssm.GetParametersInput) (*ssm.GetParametersOutput, error) {
+	return &ssm.GetParametersOutput{InvalidParameters: aws.StringSlice(s.missing)}, nil
+}
+
+func (s *MockService) GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	value, ok := s.params[*input.Name]
+	if !ok {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, "parameter not found", nil)
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Name: input.Name, Value: &value}}, nil
+}
+
+func (s *MockService) PutParameter(input *ssm.PutParameterInput) (*ssm.PutParameterOutput, error) {
+	if s.params == nil {
+		s.params = map[string]string{}
+	}
+	if _, ok := s.params[*input.Name]; ok && !aws.BoolValue(input.Overwrite) {
+		return nil, awserr.New(ssm.ErrCodeParameterAlreadyExists, "parameter already exists", nil)
+	}
+	s.params[*input.Name] = *input.Value
+	return &ssm.PutParameterOutput{}, nil
+}
+
+func (s *MockService) DeleteParameter(input *ssm.DeleteParameterInput) (*ssm.DeleteParameterOutput, error) {
+	if _, ok := s.params[*input.Name]; !ok {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, "parameter not found", nil)
+	}
+	delete(s.params, *input.Name)
+	return &ssm.DeleteParameterOutput{}, nil
+}
+
+func (s *MockService) GetParametersByPath(input *ssm.GetParametersByPathInput) (*ssm.GetParametersByPathOutput, error) {
+	params := []*ssm.Parameter{}
+	for name, value := range s.params {
+		if strings.HasPrefix(name, *input.Path+"/") && !strings.Contains(strings.TrimPrefix(name, *input.Path+"/"), "/") {
+			params = append(params, &ssm.Parameter{Name: aws.String(name), Value: aws.String(value)})
+		}
+	}
+	return &ssm.GetParametersByPathOutput{Parameters: params}, nil
+}
+
+func (s *MockService) SendEmail(input *ses.SendEmailInput) (*ses.SendEmailOutput, error) {
+	s.sei = input
+	if s.sesErr != nil {
+		return nil, s.sesErr
+	}
+	return &ses.SendEmailOutput{}, nil
+}
+
+func (s *MockService) DescribeScalableTargets(input *applicationautoscaling.DescribeScalableTargetsInput) (*applicationautoscaling.DescribeScalableTargetsOutput, error) {
+	return &applicationautoscaling.DescribeScalableTargetsOutput{ScalableTargets: s.targets}, nil
+}
+
+func (s *MockService) RegisterScalableTarget(input *applicationautoscaling.RegisterScalableTargetInput) (*applicationautoscaling.RegisterScalableTargetOutput, error) {
+	s.rsti = input
+	return &applicationautoscaling.RegisterScalableTargetOutput{}, nil
+}
+
 func Test_handleRequestECR(t *testing.T) {
 	ProjectName = "chubby"
+	Env = "dev"
 	var e events.CloudWatchEvent
 	err := json.Unmarshal([]byte(ecr_event), &e)
 	assert.NoError(t, err)
@@ -46,8 +197,582 @@ func Test_handleRequestECR(t *testing.T) {
 	assert.Equal(t, "backend_service_dev", *srv.usi.Service)
 	assert.Equal(t, "chubby_cluster_dev", *srv.usi.Cluster)
 	assert.Equal(t, "arn:aws:ecs:us-east-1:798135304365:task-definition/backend:3", *srv.usi.TaskDefinition)
+
+	var meta DeploymentMetadata
+	err = json.Unmarshal([]byte(srv.params["/dev/chubby/deployment/backend/metadata"]), &meta)
+	assert.NoError(t, err)
+	assert.Equal(t, "backend", meta.Service)
+	assert.Equal(t, "arn:aws:ecs:us-east-1:798135304365:task-definition/backend:3", meta.TaskDefinition)
+	assert.Equal(t, "latest", meta.ImageTag)
+	assert.Equal(t, "sha256:0123456789abcdef0123456789abcdef", meta.ImageDigest)
+	assert.Equal(t, e.ID, meta.EventID)
+}
+
+func Test_deploymentMetadata(t *testing.T) {
+	ProjectName = "chubby"
+	Env = "dev"
+	srv := MockService{}
+
+	writeDeploymentMetadata(&srv, DeploymentMetadata{Service: "backend", ImageTag: "sha-860c190", ImageDigest: "sha256:0123"})
+	// the same image pushed with latest tag keeps git sha
+	writeDeploymentMetadata(&srv, DeploymentMetadata{Service: "backend", ImageTag: "latest", ImageDigest: "sha256:0123"})
+
+	var meta DeploymentMetadata
+	err := json.Unmarshal([]byte(srv.params["/dev/chubby/deployment/backend/metadata"]), &meta)
+	assert.NoError(t, err)
+	assert.Equal(t, "latest", meta.ImageTag)
+	assert.Equal(t, "860c190", meta.GitSHA)
+
+	writeDeploymentMetadata(&srv, DeploymentMetadata{Service: "backend", ImageTag: "latest", ImageDigest: "sha256:4567"})
+	var next DeploymentMetadata
+	err = json.Unmarshal([]byte(srv.params["/dev/chubby/deployment/backend/metadata"]), &next)
+	assert.NoError(t, err)
+	assert.Empty(t, next.GitSHA)
+}
+
+func Test_handleRequestSSMMetadata(t *testing.T) {
+	ProjectName = "chubby"
+	Env = "dev"
+	var e events.CloudWatchEvent
+	err := json.Unmarshal([]byte(ssm_event), &e)
+	assert.NoError(t, err)
+
+	srv := MockService{}
+	writeDeploymentMetadata(&srv, DeploymentMetadata{Service: "backend", ImageTag: "sha-860c190", ImageDigest: "sha256:0123", EventID: "push"})
+
+	// env change deploys the same image again, the image of the previous deployment is kept
+	handler := Handler(&srv)
+	_, err = handler(context.TODO(), e)
+	assert.NoError(t, err)
+	assert.NotNil(t, srv.usi)
+
+	var meta DeploymentMetadata
+	err = json.Unmarshal([]byte(srv.params["/dev/chubby/deployment/backend/metadata"]), &meta)
+	assert.NoError(t, err)
+	assert.Equal(t, e.ID, meta.EventID)
+	assert.Equal(t, "sha-860c190", meta.ImageTag)
+	assert.Equal(t, "sha256:0123", meta.ImageDigest)
+	assert.Equal(t, "860c190", meta.GitSHA)
+}
+
+func Benchmark_handleRequestECR(b *testing.B) {
+	ProjectName = "chubby"
+	Env = "dev"
+	var e events.CloudWatchEvent
+	if err := json.Unmarshal([]byte(ecr_event), &e); err != nil {
+		b.Fatal(err)
+	}
+
+	handler := Handler(&MockService{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := handler(context.TODO(), e); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Test_quietHours(t *testing.T) {
+	q, err := parseQuietHours("22:00-08:00", "Australia/Sydney")
+	assert.NoError(t, err)
+
+	sydney, _ := time.LoadLocation("Australia/Sydney")
+	assert.True(t, q.Contains(time.Date(2023, 6, 1, 23, 30, 0, 0, sydney)))
+	assert.True(t, q.Contains(time.Date(2023, 6, 1, 7, 59, 0, 0, sydney)))
+	assert.False(t, q.Contains(time.Date(2023, 6, 1, 8, 0, 0, 0, sydney)))
+	assert.False(t, q.Contains(time.Date(2023, 6, 1, 12, 0, 0, 0, sydney)))
+	// 13:00 UTC is 23:00 in Sydney
+	assert.True(t, q.Contains(time.Date(2023, 6, 1, 13, 0, 0, 0, time.UTC)))
+
+	q, err = parseQuietHours("12:00-13:00", "")
+	assert.NoError(t, err)
+	assert.True(t, q.Contains(time.Date(2023, 6, 1, 12, 30, 0, 0, time.UTC)))
+	assert.False(t, q.Contains(time.Date(2023, 6, 1, 13, 30, 0, 0, time.UTC)))
+
+	_, err = parseQuietHours("22:00", "")
+	assert.Error(t, err)
+	_, err = parseQuietHours("22:00-08:00", "Mars/Olympus")
+	assert.Error(t, err)
+}
+
+func Test_eventRules(t *testing.T) {
+	EventRules = `[{"source":"aws.ecr","tag":"v\\d+\\.\\d+\\.\\d+"},{"source":"aws.ssm","parameter":".*/backend/.*"}]`
+	defer func() { EventRules = "" }()
+
+	assert.True(t, isDeploymentAllowed(EventAttributes{Source: "aws.ecr", Repository: "chubby_backend", Tag: "v1.2.3"}))
+	assert.False(t, isDeploymentAllowed(EventAttributes{Source: "aws.ecr", Repository: "chubby_backend", Tag: "v1.2.3-rc"}))
+	assert.True(t, isDeploymentAllowed(EventAttributes{Source: "aws.ssm", Parameter: "/dev/chubby/backend/pg_host"}))
+	assert.False(t, isDeploymentAllowed(EventAttributes{Source: "aws.ssm", Parameter: "/dev/chubby/worker/pg_host"}))
+	assert.False(t, isDeploymentAllowed(EventAttributes{Source: "action.production"}))
+
+	_, err := parseEventRules(`[{"tag":"v("}]`)
+	assert.Error(t, err)
+
+	// a typo in the rules doesn't allow every deployment
+	EventRules = `[{"source":"aws.ecr","tag":"v("}]`
+	assert.False(t, isDeploymentAllowed(EventAttributes{Source: "aws.ecr", Repository: "chubby_backend", Tag: "v1.2.3"}))
+	EventRules = `[{"source":"aws.ecr","tag":"v\\d+\\.\\d+\\.\\d+"}]`
+
+	ProjectName = "chubby"
+	Env = "dev"
+	var e events.CloudWatchEvent
+	err = json.Unmarshal([]byte(ecr_event), &e)
+	assert.NoError(t, err)
+
+	srv := MockService{}
+	handler := Handler(&srv)
+	result, err := handler(context.TODO(), e)
+	assert.NoError(t, err)
+	assert.Contains(t, result, "Skipping image chubby_backend:latest, it does not match event rules")
+	assert.Nil(t, srv.usi)
+}
+
+func Test_handleRequestECSQuietHours(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	Env = "dev"
+	SlackWebhookURL = server.URL
+	// the events are sent at 12:31 UTC
+	SlackQuietHours = "12:00-13:00"
+	defer func() { SlackWebhookURL, SlackQuietHours = "", "" }()
+
+	for event, expected := range map[string]string{
+		ecs_event_success:  "no messages sent for INFO and SERVICE_DEPLOYMENT_COMPLETED.",
+		ecs_event_failed:   "sent slack message for ERROR and SERVICE_DEPLOYMENT_FAILED.",
+		ecs_event_impaired: "sent slack message for WARN and SERVICE_TASK_START_IMPAIRED.",
+	} {
+		var e events.CloudWatchEvent
+		err := json.Unmarshal([]byte(event), &e)
+		assert.NoError(t, err)
+
+		handler := Handler(&MockService{})
+		result, err := handler(context.TODO(), e)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, result)
+	}
+}
+
+func Test_grafanaAnnotation(t *testing.T) {
+	var annotation GrafanaAnnotation
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/annotations", r.URL.Path)
+		auth = r.Header.Get("Authorization")
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&annotation))
+	}))
+	defer server.Close()
+
+	Env = "dev"
+	GrafanaURL = server.URL + "/"
+	GrafanaAPIKey = "secret"
+	defer func() { GrafanaURL = "" }()
+
+	var e events.CloudWatchEvent
+	err := json.Unmarshal([]byte(ecs_event_success), &e)
+	assert.NoError(t, err)
+
+	ProjectName = "chubby"
+	srv := MockService{
+		services: []*ecs.Service{{
+			ServiceName: aws.String("servicetest"),
+			Deployments: []*ecs.Deployment{{
+				Id:             aws.String("ecs-svc/123"),
+				TaskDefinition: aws.String("arn:aws:ecs:us-west-2:111122223333:task-definition/servicetest:3"),
+			}},
+		}},
+	}
+	writeDeploymentMetadata(&srv, DeploymentMetadata{
+		Service:        "servicetest",
+		TaskDefinition: "arn:aws:ecs:us-west-2:111122223333:task-definition/servicetest:3",
+		ImageTag:       "sha-860c190",
+		ImageDigest:    "sha256:0123",
+		EventID:        "01234567-0123-0123-0123-012345678912",
+	})
+
+	handler := Handler(&srv)
+	_, err = handler(context.TODO(), e)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Bearer secret", auth)
+	assert.Equal(t, e.Time.UnixMilli(), annotation.Time)
+	assert.Equal(t, []string{"deployment", "dev", "servicetest"}, annotation.Tags)
+	assert.Equal(t, "[dev]: Service servicetest deployed servicetest:3 image sha-860c190 commit 860c190 (ecs-svc/123) by ci_lambda, started by event 01234567-0123-0123-0123-012345678912", annotation.Text)
+}
+
+func Test_handleRequestECSCommit(t *testing.T) {
+	var message string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/madappgang/chubby/commits/860c190" {
+			_, _ = w.Write([]byte(`{"commit":{"message":"Fix \"login\" <redirect>\n\nDetails","author":{"name":"Jane Doe"}}}`))
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		message = string(body)
+	}))
+	defer server.Close()
+
+	ProjectName = "chubby"
+	Env = "dev"
+	SlackWebhookURL = server.URL
+	GitHubRepository = "madappgang/chubby"
+	githubAPIURL = server.URL
+	defer func() { SlackWebhookURL, GitHubRepository, githubAPIURL = "", "", "https://api.github.com" }()
+
+	srv := MockService{
+		services: []*ecs.Service{{
+			ServiceName: aws.String("servicetest"),
+			Deployments: []*ecs.Deployment{{
+				Id:             aws.String("ecs-svc/123"),
+				TaskDefinition: aws.String("arn:aws:ecs:us-west-2:111122223333:task-definition/servicetest:4"),
+			}},
+		}},
+	}
+	writeDeploymentMetadata(&srv, DeploymentMetadata{Service: "servicetest", ImageTag: "sha-1a2b3c4", ImageDigest: "sha256:0123"})
+	writeDeploymentMetadata(&srv, DeploymentMetadata{
+		Service:        "servicetest",
+		TaskDefinition: "arn:aws:ecs:us-west-2:111122223333:task-definition/servicetest:4",
+		ImageTag:       "sha-860c190",
+		ImageDigest:    "sha256:4567",
+	})
+
+	var e events.CloudWatchEvent
+	err := json.Unmarshal([]byte(ecs_event_success), &e)
+	assert.NoError(t, err)
+
+	handler := Handler(&srv)
+	_, err = handler(context.TODO(), e)
+	assert.NoError(t, err)
+
+	assert.Contains(t, message, `Commit <https://github.com/madappgang/chubby/commit/860c190|860c190> by Jane Doe: Fix \"login\" &lt;redirect&gt;`)
+	assert.Contains(t, message, "<https://github.com/madappgang/chubby/compare/1a2b3c4...860c190|Changes since the previous deployment>")
+}
+
+func Test_handleRequestECSFailed(t *testing.T) {
+	var message string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		message = string(body)
+	}))
+	defer server.Close()
+
+	Env = "dev"
+	SlackWebhookURL = server.URL
+	defer func() { SlackWebhookURL = "" }()
+
+	var e events.CloudWatchEvent
+	err := json.Unmarshal([]byte(ecs_event_failed), &e)
+	assert.NoError(t, err)
+
+	srv := MockService{}
+	handler := Handler(&srv)
+	result, err := handler(context.TODO(), e)
+	assert.NoError(t, err)
+	assert.Contains(t, result, "sent slack message")
+
+	assert.NotNil(t, srv.lti)
+	assert.Equal(t, "default", *srv.lti.Cluster)
+	assert.Equal(t, "servicetest", *srv.lti.ServiceName)
+	assert.Equal(t, ecs.DesiredStatusStopped, *srv.lti.DesiredStatus)
+
+	assert.Contains(t, message, `\n• task 0123456789abcdef stopped: Essential container in task exited`)
+	assert.Contains(t, message, "chubby_backend_dev exit code 137 (OutOfMemoryError: Container killed due to memory usage)")
+	assert.Contains(t, message, "event ddca6449-b258-46c0-8653-e0e3aEXAMPLE, deployment ecs-svc/123")
+}
+
+func Test_handleRequestECSEmail(t *testing.T) {
+	Env = "dev"
+	EmailSender = "deploy@example.com"
+	EmailRecipients = "dev@example.com,qa@example.com"
+	defer func() { EmailSender, EmailRecipients = "", "" }()
+
+	var e events.CloudWatchEvent
+	err := json.Unmarshal([]byte(ecs_event_failed), &e)
+	assert.NoError(t, err)
+
+	srv := MockService{}
+	handler := Handler(&srv)
+	result, err := handler(context.TODO(), e)
+	assert.NoError(t, err)
+	assert.Contains(t, result, "sent email message")
+
+	assert.NotNil(t, srv.sei)
+	assert.Equal(t, "deploy@example.com", *srv.sei.Source)
+	assert.Equal(t, []string{"dev@example.com", "qa@example.com"}, aws.StringValueSlice(srv.sei.Destination.ToAddresses))
+	assert.Equal(t, "[dev] servicetest: SERVICE_DEPLOYMENT_FAILED", *srv.sei.Message.Subject.Data)
+	assert.Contains(t, *srv.sei.Message.Body.Text.Data, "- task 0123456789abcdef stopped: Essential container in task exited")
+	assert.Contains(t, *srv.sei.Message.Body.Text.Data, "Event ddca6449-b258-46c0-8653-e0e3aEXAMPLE, deployment ecs-svc/123")
+}
+
+func Test_handleRequestECSEmailFailed(t *testing.T) {
+	var message string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		message = string(body)
+	}))
+	defer server.Close()
+
+	Env = "dev"
+	SlackWebhookURL = server.URL
+	EmailSender = "deploy@example.com"
+	EmailRecipients = "dev@example.com"
+	defer func() { SlackWebhookURL, EmailSender, EmailRecipients = "", "", "" }()
+
+	var e events.CloudWatchEvent
+	err := json.Unmarshal([]byte(ecs_event_failed), &e)
+	assert.NoError(t, err)
+
+	// slack message is sent even if SES rejects the email
+	srv := MockService{sesErr: awserr.New(ses.ErrCodeMessageRejected, "email address is not verified", nil)}
+	handler := Handler(&srv)
+	result, err := handler(context.TODO(), e)
+	assert.NoError(t, err)
+	assert.Equal(t, "sent slack message for ERROR and SERVICE_DEPLOYMENT_FAILED.", result)
+	assert.Contains(t, message, "servicetest")
+}
+
+func Test_handleRequestIncident(t *testing.T) {
+	ProjectName = "chubby"
+	Env = "dev"
+
+	srv := MockService{}
+	handler := Handler(&srv)
+
+	var start, end, ecr events.CloudWatchEvent
+	assert.NoError(t, json.Unmarshal([]byte(incident_event_start), &start))
+	assert.NoError(t, json.Unmarshal([]byte(incident_event_end), &end))
+	assert.NoError(t, json.Unmarshal([]byte(ecr_event), &ecr))
+
+	result, err := handler(context.TODO(), start)
+	assert.NoError(t, err)
+	assert.Contains(t, result, "Incident started")
+	assert.Contains(t, srv.params, "/dev/chubby/incident")
+
+	result, err = handler(context.TODO(), start)
+	assert.NoError(t, err)
+	assert.Contains(t, result, "Incident is already active")
+
+	result, err = handler(context.TODO(), ecr)
+	assert.NoError(t, err)
+	assert.Contains(t, result, "Deployment of backend is queued")
+	assert.Nil(t, srv.usi)
+	assert.Contains(t, srv.params, "/dev/chubby/incident/queue/backend")
+
+	result, err = handler(context.TODO(), ecr)
+	assert.NoError(t, err)
+	assert.Contains(t, result, "Deployment of backend is already queued")
+
+	result, err = handler(context.TODO(), end)
+	assert.NoError(t, err)
+	assert.Contains(t, result, "released queued deployments: backend")
+	assert.NotContains(t, srv.params, "/dev/chubby/incident")
+	assert.NotContains(t, srv.params, "/dev/chubby/incident/queue/backend")
+	assert.NotNil(t, srv.usi)
+	assert.Equal(t, "backend_service_dev", *srv.usi.Service)
+
+	// queued image is kept in deployment metadata
+	var meta DeploymentMetadata
+	err = json.Unmarshal([]byte(srv.params["/dev/chubby/deployment/backend/metadata"]), &meta)
+	assert.NoError(t, err)
+	assert.Equal(t, "sha256:0123456789abcdef0123456789abcdef", meta.ImageDigest)
+}
+
+func Test_handleRequestIncidentFailedRelease(t *testing.T) {
+	ProjectName = "chubby"
+	Env = "dev"
+
+	srv := MockService{}
+	handler := Handler(&srv)
+
+	var start, end, ecr events.CloudWatchEvent
+	assert.NoError(t, json.Unmarshal([]byte(incident_event_start), &start))
+	assert.NoError(t, json.Unmarshal([]byte(incident_event_end), &end))
+	assert.NoError(t, json.Unmarshal([]byte(ecr_event), &ecr))
+
+	_, err := handler(context.TODO(), start)
+	assert.NoError(t, err)
+	_, err = handler(context.TODO(), ecr)
+	assert.NoError(t, err)
+
+	// failed deployment is kept in the queue
+	srv.missing = []string{"/dev/chubby/backend/pg_database_password"}
+	_, err = handler(context.TODO(), end)
+	assert.ErrorContains(t, err, "unable to deploy queued services: backend")
+	assert.NotContains(t, srv.params, "/dev/chubby/incident")
+	assert.Contains(t, srv.params, "/dev/chubby/incident/queue/backend")
+	assert.Nil(t, srv.usi)
+
+	// retried end releases the queue left by the failed one
+	srv.missing = nil
+	result, err := handler(context.TODO(), end)
+	assert.NoError(t, err)
+	assert.Contains(t, result, "released queued deployments: backend")
+	assert.NotContains(t, srv.params, "/dev/chubby/incident/queue/backend")
+	assert.NotNil(t, srv.usi)
+
+	result, err = handler(context.TODO(), end)
+	assert.NoError(t, err)
+	assert.Equal(t, "No active incident, nothing to end", result)
 }
 
+func Test_queueDeploymentAfterIncidentEnd(t *testing.T) {
+	ProjectName = "chubby"
+	Env = "dev"
+
+	// the incident has ended after the deployment checked it, the deployment releases itself
+	srv := MockService{}
+	result, err := queueDeployment(&srv, "backend", DeploymentMetadata{ImageTag: "latest"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Incident has ended, deployment of backend is released", result)
+	assert.NotContains(t, srv.params, "/dev/chubby/incident/queue/backend")
+	assert.NotNil(t, srv.usi)
+}
+
+func Test_handleRequestHibernate(t *testing.T) {
+	ProjectName = "chubby"
+	Env = "dev"
+	HibernateDBIdentifier = "chubby-postgres-dev"
+	defer func() { HibernateDBIdentifier = "" }()
+
+	srv := MockService{
+		services: []*ecs.Service{{
+			ServiceArn:   aws.String("arn:aws:ecs:us-east-1:798135304365:service/chubby_cluster_dev/backend_service_dev"),
+			ServiceName:  aws.String("backend_service_dev"),
+			DesiredCount: aws.Int64(2),
+		}},
+		targets: []*applicationautoscaling.ScalableTarget{{
+			ResourceId: aws.String("service/chubby_cluster_dev/backend_service_dev"),
+		}},
+	}
+	handler := Handler(&srv)
+
+	var e events.CloudWatchEvent
+	err := json.Unmarshal([]byte(hibernate_event_sleep), &e)
+	assert.NoError(t, err)
+	result, err := handler(context.TODO(), e)
+	assert.NoError(t, err)
+	assert.Equal(t, "Processed hibernate sleep for services: backend_service_dev", result)
+	assert.Equal(t, int64(0), *srv.usi.DesiredCount)
+	assert.Equal(t, "chubby_cluster_dev", *srv.usi.Cluster)
+	assert.Equal(t, "2", srv.params["/dev/chubby/hibernate/backend_service_dev/desired_count"])
+	assert.Equal(t, "service/chubby_cluster_dev/backend_service_dev", *srv.rsti.ResourceId)
+	assert.True(t, *srv.rsti.SuspendedState.DynamicScalingOutSuspended)
+	assert.Equal(t, "stopped chubby-postgres-dev", srv.db)
+
+	srv.services[0].DesiredCount = aws.Int64(0)
+	srv.usi = nil
+
+	// services wait for the database to start
+	err = json.Unmarshal([]byte(hibernate_event_wake), &e)
+	assert.NoError(t, err)
+	result, err = handler(context.TODO(), e)
+	assert.NoError(t, err)
+	assert.Equal(t, "Starting database chubby-postgres-dev, services are woken up when it is available", result)
+	assert.Equal(t, "started chubby-postgres-dev", srv.db)
+	assert.Nil(t, srv.usi)
+
+	err = json.Unmarshal([]byte(rds_event_started), &e)
+	assert.NoError(t, err)
+	result, err = handler(context.TODO(), e)
+	assert.NoError(t, err)
+	assert.Equal(t, "Processed hibernate wake for services: backend_service_dev", result)
+	assert.Equal(t, int64(2), *srv.usi.DesiredCount)
+	assert.NotContains(t, srv.params, "/dev/chubby/hibernate/backend_service_dev/desired_count")
+	assert.False(t, *srv.rsti.SuspendedState.DynamicScalingOutSuspended)
+
+	// repeated event leaves already woken up services as is
+	srv.usi = nil
+	result, err = handler(context.TODO(), e)
+	assert.NoError(t, err)
+	assert.Equal(t, "Processed hibernate wake for services: ", result)
+	assert.Nil(t, srv.usi)
+}
+
+func Test_handleRequestECRMissingSecrets(t *testing.T) {
+	ProjectName = "chubby"
+	Env = "dev"
+	var e events.CloudWatchEvent
+	err := json.Unmarshal([]byte(ecr_event), &e)
+	assert.NoError(t, err)
+
+	srv := MockService{missing: []string{"/dev/chubby/backend/pg_database_password"}}
+	handler := Handler(&srv)
+	_, err = handler(context.TODO(), e)
+	assert.ErrorContains(t, err, "references missing SSM parameters: /dev/chubby/backend/pg_database_password")
+	assert.Nil(t, srv.usi)
+}
+
+const hibernate_event_sleep = `
+{
+  "source": "action.hibernate",
+  "detail-type": "HIBERNATE",
+  "detail": {
+    "action": "sleep"
+  }
+}
+`
+
+const hibernate_event_wake = `
+{
+  "source": "action.hibernate",
+  "detail-type": "HIBERNATE",
+  "detail": {
+    "action": "wake"
+  }
+}
+`
+
+const rds_event_started = `
+{
+  "version": "0",
+  "id": "68f6e973-1a0c-d37b-f2f2-94a7f62ffd4e",
+  "detail-type": "RDS DB Instance Event",
+  "source": "aws.rds",
+  "account": "798135304365",
+  "time": "2023-06-12T07:04:44Z",
+  "region": "us-east-1",
+  "resources": [
+    "arn:aws:rds:us-east-1:798135304365:db:chubby-postgres-dev"
+  ],
+  "detail": {
+    "EventCategories": [
+      "notification"
+    ],
+    "SourceType": "DB_INSTANCE",
+    "SourceArn": "arn:aws:rds:us-east-1:798135304365:db:chubby-postgres-dev",
+    "Date": "2023-06-12T07:04:44.091Z",
+    "Message": "DB instance started",
+    "SourceIdentifier": "chubby-postgres-dev",
+    "EventID": "RDS-EVENT-0088"
+  }
+}
+`
+
+const incident_event_start = `
+{
+  "source": "action.incident",
+  "detail-type": "INCIDENT",
+  "detail": {
+    "action": "start",
+    "reason": "database is down"
+  }
+}
+`
+
+const incident_event_end = `
+{
+  "source": "action.incident",
+  "detail-type": "INCIDENT",
+  "detail": {
+    "action": "end"
+  }
+}
+`
+
 const ecr_event = `
 {
   "version": "0",
@@ -71,6 +796,27 @@ const ecr_event = `
 }
 `
 
+const ssm_event = `
+{
+  "version": "0",
+  "id": "6a7e4feb-b491-4cf7-a9f1-bf3703497718",
+  "detail-type": "Parameter Store Change",
+  "source": "aws.ssm",
+  "account": "123456789012",
+  "time": "2017-05-22T16:43:48Z",
+  "region": "us-east-1",
+  "resources": [
+    "arn:aws:ssm:us-east-1:123456789012:parameter/dev/chubby/backend/pg_host"
+  ],
+  "detail": {
+    "operation": "Update",
+    "name": "/dev/chubby/backend/pg_host",
+    "type": "String",
+    "description": ""
+  }
+}
+`
+
 const ecs_event_success = `
 {
    "version": "0",
@@ -114,3 +860,24 @@ const ecs_event_failed = `
    }
 }
 `
+
+const ecs_event_impaired = `
+{
+   "version": "0",
+   "id": "ddca6449-b258-46c0-8653-e0e3aEXAMPLE",
+   "detail-type": "ECS Service Action",
+   "source": "aws.ecs",
+   "account": "111122223333",
+   "time": "2020-05-23T12:31:14Z",
+   "region": "us-west-2",
+   "resources": [
+        "arn:aws:ecs:us-west-2:111122223333:service/default/servicetest"
+   ],
+   "detail": {
+        "eventType": "WARN",
+        "eventName": "SERVICE_TASK_START_IMPAIRED",
+        "clusterArn": "arn:aws:ecs:us-west-2:111122223333:cluster/default",
+        "createdAt": "2020-05-23T12:31:14.695Z"
+   }
+}
+`
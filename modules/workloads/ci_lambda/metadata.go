@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// DeploymentMetadata is written to SSM after every deployment, so the running service can report its own version
+type DeploymentMetadata struct {
+	Service        string    `json:"service"`
+	TaskDefinition string    `json:"task_definition"`
+	ImageTag       string    `json:"image_tag,omitempty"`
+	ImageDigest    string    `json:"image_digest,omitempty"`
+	GitSHA         string    `json:"git_sha,omitempty"`
+	PreviousGitSHA string    `json:"previous_git_sha,omitempty"` // git sha deployed before, to compare the changes
+	DeployedAt     time.Time `json:"deployed_at"`
+	EventID        string    `json:"event_id"`
+}
+
+// shaTagRe matches the image tags pushed by docker/metadata-action with type=sha, e.g. sha-860c190
+var shaTagRe = regexp.MustCompile(`^sha-([0-9a-f]{7,40})$`)
+
+// deploymentMetadataParameterName is outside of the service parameters path, so it doesn't trigger SSM deployments
+// and is not added to the service env
+func deploymentMetadataParameterName(service string) string {
+	return fmt.Sprintf("/%s/%s/deployment/%s/metadata", Env, ProjectName, service)
+}
+
+// writeDeploymentMetadata stores the metadata of the deployment, the service is already updated, so errors are logged only
+func writeDeploymentMetadata(srv Service, meta DeploymentMetadata) {
+	name := deploymentMetadataParameterName(meta.Service)
+
+	var previous DeploymentMetadata
+	if p, err := srv.GetParameter(&ssm.GetParameterInput{Name: &name}); err == nil {
+		if err := json.Unmarshal([]byte(aws.StringValue(p.Parameter.Value)), &previous); err != nil {
+			fmt.Printf("Unable to unmarshal previous deployment metadata: %v.\n", err)
+		}
+	}
+
+	if len(meta.ImageDigest) == 0 {
+		// SSM and production deployments run the image of the previous deployment again
+		meta.ImageTag, meta.ImageDigest, meta.GitSHA = previous.ImageTag, previous.ImageDigest, previous.GitSHA
+	} else if match := shaTagRe.FindStringSubmatch(meta.ImageTag); len(match) == 2 {
+		meta.GitSHA = match[1]
+	} else if previous.ImageDigest == meta.ImageDigest {
+		// the same image is pushed with sha and latest tags, keep git sha of the image from the previous push
+		meta.GitSHA = previous.GitSHA
+	}
+
+	meta.PreviousGitSHA = previous.PreviousGitSHA
+	if meta.GitSHA != previous.GitSHA {
+		meta.PreviousGitSHA = previous.GitSHA
+	}
+
+	value, err := json.Marshal(meta)
+	if err != nil {
+		fmt.Printf("Unable to marshal deployment metadata: %v.\n", err)
+		return
+	}
+
+	_, err = srv.PutParameter(&ssm.PutParameterInput{
+		Name:      &name,
+		Type:      aws.String(ssm.ParameterTypeString),
+		Value:     aws.String(string(value)),
+		Overwrite: aws.Bool(true),
+	})
+	if err != nil {
+		fmt.Printf("Unable to write deployment metadata to %s: %v.\n", name, err)
+	}
+}
+
+// findDeploymentMetadata returns the task definition deployed by the ECS deployment,
+// and the metadata written by ci_lambda when it is for the same task definition, nil otherwise.
+// The details are nice to have for notifications, so errors are logged only.
+func findDeploymentMetadata(srv Service, serviceARN, deploymentID string) (string, *DeploymentMetadata) {
+	cluster, service := getClusterAndServiceFromARN(serviceARN)
+	described, err := srv.DescribeServices(&ecs.DescribeServicesInput{
+		Cluster:  &cluster,
+		Services: []*string{&service},
+	})
+	if err != nil {
+		fmt.Printf("Unable to describe service %s: %v.\n", service, err)
+		return "", nil
+	}
+
+	var taskDefinition string
+	for _, s := range described.Services {
+		for _, d := range s.Deployments {
+			if aws.StringValue(d.Id) == deploymentID {
+				taskDefinition = aws.StringValue(d.TaskDefinition)
+			}
+		}
+	}
+	if len(taskDefinition) == 0 {
+		return "", nil
+	}
+
+	name := deploymentMetadataParameterName(strings.TrimSuffix(service, "_service_"+Env))
+	param, err := srv.GetParameter(&ssm.GetParameterInput{Name: &name})
+	if err != nil {
+		return taskDefinition, nil
+	}
+	var meta DeploymentMetadata
+	if err := json.Unmarshal([]byte(aws.StringValue(param.Parameter.Value)), &meta); err != nil || meta.TaskDefinition != taskDefinition {
+		return taskDefinition, nil
+	}
+	return taskDefinition, &meta
+}
@@ -19,5 +19,8 @@ func processProductionDeployEvent(srv Service, ctx context.Context, e events.Clo
 		return "", fmt.Errorf("could not unmarshal event detail: %v", err)
 	}
 	fmt.Printf("New deploy command for service %s.\n", detail.Service)
-	return deploy(srv, detail.Service)
+	if !isDeploymentAllowed(EventAttributes{Source: e.Source, DetailType: e.DetailType}) {
+		return fmt.Sprintf("Skipping deploy command for service %s, it does not match event rules", detail.Service), nil
+	}
+	return deploy(srv, detail.Service, DeploymentMetadata{})
 }
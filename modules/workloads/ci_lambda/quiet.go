@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // lambda runtime could miss zoneinfo, embed it to be able to load any timezone
+)
+
+// QuietHours is a daily time window when only failure notifications are sent to slack.
+// The window could span midnight, e.g. 22:00-08:00.
+type QuietHours struct {
+	From     time.Duration // offset from midnight
+	To       time.Duration // offset from midnight
+	Location *time.Location
+}
+
+// parseQuietHours parses a window in "HH:MM-HH:MM" format, the timezone is an IANA name, UTC if empty.
+func parseQuietHours(window, timezone string) (*QuietHours, error) {
+	from, to, found := strings.Cut(window, "-")
+	if !found {
+		return nil, fmt.Errorf("invalid quiet hours window %q, expected format HH:MM-HH:MM", window)
+	}
+
+	fromOffset, err := parseClock(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quiet hours window %q: %v", window, err)
+	}
+	toOffset, err := parseClock(to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quiet hours window %q: %v", window, err)
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quiet hours timezone %q: %v", timezone, err)
+	}
+
+	return &QuietHours{From: fromOffset, To: toOffset, Location: location}, nil
+}
+
+func parseClock(str string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(str))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t is inside the quiet hours window.
+func (q *QuietHours) Contains(t time.Time) bool {
+	local := t.In(q.Location)
+	offset := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+
+	if q.From <= q.To {
+		return offset >= q.From && offset < q.To
+	}
+	// window spans midnight
+	return offset >= q.From || offset < q.To
+}
+
+// isQuietTime reports whether non-failure slack notifications should be skipped at t.
+func isQuietTime(t time.Time) bool {
+	if len(SlackQuietHours) == 0 {
+		return false
+	}
+
+	q, err := parseQuietHours(SlackQuietHours, SlackQuietHoursTimezone)
+	if err != nil {
+		fmt.Printf("Ignoring quiet hours: %v.\n", err)
+		return false
+	}
+	return q.Contains(t)
+}
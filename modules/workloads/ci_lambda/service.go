@@ -2,22 +2,45 @@ package main
 
 import (
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
 	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
 type Service interface {
 	ListTaskDefinitions(*ecs.ListTaskDefinitionsInput) (*ecs.ListTaskDefinitionsOutput, error)
 	UpdateService(*ecs.UpdateServiceInput) (*ecs.UpdateServiceOutput, error)
+	ListTasks(*ecs.ListTasksInput) (*ecs.ListTasksOutput, error)
+	DescribeTasks(*ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error)
+	ListServices(*ecs.ListServicesInput) (*ecs.ListServicesOutput, error)
+	DescribeServices(*ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error)
+	StopDBInstance(*rds.StopDBInstanceInput) (*rds.StopDBInstanceOutput, error)
+	StartDBInstance(*rds.StartDBInstanceInput) (*rds.StartDBInstanceOutput, error)
+	DescribeDBInstances(*rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error)
+	DescribeTaskDefinition(*ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error)
+	GetParameters(*ssm.GetParametersInput) (*ssm.GetParametersOutput, error)
+	GetParameter(*ssm.GetParameterInput) (*ssm.GetParameterOutput, error)
+	GetParametersByPath(*ssm.GetParametersByPathInput) (*ssm.GetParametersByPathOutput, error)
+	PutParameter(*ssm.PutParameterInput) (*ssm.PutParameterOutput, error)
+	DeleteParameter(*ssm.DeleteParameterInput) (*ssm.DeleteParameterOutput, error)
+	SendEmail(*ses.SendEmailInput) (*ses.SendEmailOutput, error)
+	DescribeScalableTargets(*applicationautoscaling.DescribeScalableTargetsInput) (*applicationautoscaling.DescribeScalableTargetsOutput, error)
+	RegisterScalableTarget(*applicationautoscaling.RegisterScalableTargetInput) (*applicationautoscaling.RegisterScalableTargetOutput, error)
 }
 
 type AWSService struct {
-	e *ecs.ECS
+	e     *ecs.ECS
+	r     *rds.RDS
+	s     *ssm.SSM
+	email *ses.SES
+	a     *applicationautoscaling.ApplicationAutoScaling
 }
 
 func NewAWSService() *AWSService {
 	sess := session.Must(session.NewSession())
-	svc := ecs.New(sess)
-	return &AWSService{e: svc}
+	return &AWSService{e: ecs.New(sess), r: rds.New(sess), s: ssm.New(sess), email: ses.New(sess), a: applicationautoscaling.New(sess)}
 }
 
 func (s *AWSService) ListTaskDefinitions(input *ecs.ListTaskDefinitionsInput) (*ecs.ListTaskDefinitionsOutput, error) {
@@ -27,3 +50,67 @@ func (s *AWSService) ListTaskDefinitions(input *ecs.ListTaskDefinitionsInput) (*
 func (s *AWSService) UpdateService(input *ecs.UpdateServiceInput) (*ecs.UpdateServiceOutput, error) {
 	return s.e.UpdateService(input)
 }
+
+func (s *AWSService) ListTasks(input *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	return s.e.ListTasks(input)
+}
+
+func (s *AWSService) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	return s.e.DescribeTasks(input)
+}
+
+func (s *AWSService) ListServices(input *ecs.ListServicesInput) (*ecs.ListServicesOutput, error) {
+	return s.e.ListServices(input)
+}
+
+func (s *AWSService) DescribeServices(input *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	return s.e.DescribeServices(input)
+}
+
+func (s *AWSService) StopDBInstance(input *rds.StopDBInstanceInput) (*rds.StopDBInstanceOutput, error) {
+	return s.r.StopDBInstance(input)
+}
+
+func (s *AWSService) StartDBInstance(input *rds.StartDBInstanceInput) (*rds.StartDBInstanceOutput, error) {
+	return s.r.StartDBInstance(input)
+}
+
+func (s *AWSService) DescribeDBInstances(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+	return s.r.DescribeDBInstances(input)
+}
+
+func (s *AWSService) DescribeTaskDefinition(input *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	return s.e.DescribeTaskDefinition(input)
+}
+
+func (s *AWSService) GetParameters(input *ssm.GetParametersInput) (*ssm.GetParametersOutput, error) {
+	return s.s.GetParameters(input)
+}
+
+func (s *AWSService) GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	return s.s.GetParameter(input)
+}
+
+func (s *AWSService) GetParametersByPath(input *ssm.GetParametersByPathInput) (*ssm.GetParametersByPathOutput, error) {
+	return s.s.GetParametersByPath(input)
+}
+
+func (s *AWSService) PutParameter(input *ssm.PutParameterInput) (*ssm.PutParameterOutput, error) {
+	return s.s.PutParameter(input)
+}
+
+func (s *AWSService) DeleteParameter(input *ssm.DeleteParameterInput) (*ssm.DeleteParameterOutput, error) {
+	return s.s.DeleteParameter(input)
+}
+
+func (s *AWSService) SendEmail(input *ses.SendEmailInput) (*ses.SendEmailOutput, error) {
+	return s.email.SendEmail(input)
+}
+
+func (s *AWSService) DescribeScalableTargets(input *applicationautoscaling.DescribeScalableTargetsInput) (*applicationautoscaling.DescribeScalableTargetsOutput, error) {
+	return s.a.DescribeScalableTargets(input)
+}
+
+func (s *AWSService) RegisterScalableTarget(input *applicationautoscaling.RegisterScalableTargetInput) (*applicationautoscaling.RegisterScalableTargetOutput, error) {
+	return s.a.RegisterScalableTarget(input)
+}
@@ -26,11 +26,13 @@ func processSSMEvent(srv Service, ctx context.Context, e events.CloudWatchEvent)
 
 	// project name:
 	//"$env/$project/$service/xxxxxx"
-	re := regexp.MustCompile(fmt.Sprintf(`\/?%s\/%s\/(\w+)\/\w+$`, Env, ProjectName))
-	match := re.FindStringSubmatch(detail.Name)
+	match := serviceParameterRegexp().FindStringSubmatch(detail.Name)
 	if len(match) == 2 {
+		if !isDeploymentAllowed(EventAttributes{Source: e.Source, DetailType: e.DetailType, Parameter: detail.Name}) {
+			return fmt.Sprintf("Skipping SSM parameter %s, it does not match event rules", detail.Name), nil
+		}
 		fmt.Printf("env variables in SSM key %s changed (%s) for service %s", detail.Name, detail.Operation, match[1])
-		return deploy(srv, match[1])
+		return deploy(srv, match[1], DeploymentMetadata{})
 	}
 
 	result := fmt.Sprintf("SSM parameter with key %s does not fit to any service environment, skipping", detail.Name)
@@ -38,3 +40,14 @@ func processSSMEvent(srv Service, ctx context.Context, e events.CloudWatchEvent)
 
 	return result, nil
 }
+
+var serviceParameterRe *regexp.Regexp
+
+// serviceParameterRegexp compiles the service parameter regexp once, it depends on env and project name only
+func serviceParameterRegexp() *regexp.Regexp {
+	pattern := fmt.Sprintf(`\/?%s\/%s\/(\w+)\/\w+$`, Env, ProjectName)
+	if serviceParameterRe == nil || serviceParameterRe.String() != pattern {
+		serviceParameterRe = regexp.MustCompile(pattern)
+	}
+	return serviceParameterRe
+}
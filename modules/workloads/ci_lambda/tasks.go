@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// maxStoppedTasks limits how many stopped tasks are described in a slack message
+const maxStoppedTasks = 3
+
+// getStoppedTasksReasons describes the recently stopped tasks of the service,
+// with the stop reason and exit code of every container, to explain why the deployment failed.
+func getStoppedTasksReasons(srv Service, serviceARN string) ([]string, error) {
+	cluster, service := getClusterAndServiceFromARN(serviceARN)
+
+	taskList, err := srv.ListTasks(&ecs.ListTasksInput{
+		Cluster:       &cluster,
+		ServiceName:   &service,
+		DesiredStatus: aws.String(ecs.DesiredStatusStopped),
+		MaxResults:    aws.Int64(maxStoppedTasks),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list stopped tasks: %v", err)
+	}
+	if len(taskList.TaskArns) == 0 {
+		return nil, nil
+	}
+
+	tasks, err := srv.DescribeTasks(&ecs.DescribeTasksInput{
+		Cluster: &cluster,
+		Tasks:   taskList.TaskArns,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe stopped tasks: %v", err)
+	}
+
+	reasons := []string{}
+	for _, task := range tasks.Tasks {
+		containers := []string{}
+		for _, c := range task.Containers {
+			container := aws.StringValue(c.Name)
+			if c.ExitCode != nil {
+				container += fmt.Sprintf(" exit code %d", aws.Int64Value(c.ExitCode))
+			}
+			if len(aws.StringValue(c.Reason)) > 0 {
+				container += fmt.Sprintf(" (%s)", aws.StringValue(c.Reason))
+			}
+			containers = append(containers, container)
+		}
+		reason := fmt.Sprintf("task %s stopped: %s", getResourceNameFromARN(aws.StringValue(task.TaskArn)), aws.StringValue(task.StoppedReason))
+		if len(containers) > 0 {
+			reason += ", containers: " + strings.Join(containers, ", ")
+		}
+		reasons = append(reasons, reason)
+	}
+	return reasons, nil
+}
+
+// getClusterAndServiceFromARN extracts cluster and service names from service arn,
+// e.g. arn:aws:ecs:us-west-2:111122223333:service/default/servicetest -> default, servicetest
+// The old arn format has no cluster name, so the project cluster is used.
+func getClusterAndServiceFromARN(arn string) (string, string) {
+	parts := strings.Split(arn, "/")
+	if len(parts) == 3 {
+		return parts[1], parts[2]
+	}
+	return fmt.Sprintf("%s_cluster_%s", ProjectName, Env), parts[len(parts)-1]
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// maxGetParameters is the max number of names accepted by SSM GetParameters at once
+const maxGetParameters = 10
+
+// validateTaskDefinitionSecrets checks that every SSM parameter referenced by the task definition secrets exists,
+// so the deployment fails fast instead of tasks crashing on startup.
+// Secrets Manager references are not checked.
+func validateTaskDefinitionSecrets(srv Service, taskDefinition string) error {
+	td, err := srv.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: &taskDefinition,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to describe task definition: %v", err)
+	}
+
+	names := []*string{}
+	for _, c := range td.TaskDefinition.ContainerDefinitions {
+		for _, s := range c.Secrets {
+			if strings.Contains(aws.StringValue(s.ValueFrom), ":secretsmanager:") {
+				continue
+			}
+			names = append(names, s.ValueFrom)
+		}
+	}
+
+	missing := []string{}
+	for i := 0; i < len(names); i += maxGetParameters {
+		end := i + maxGetParameters
+		if end > len(names) {
+			end = len(names)
+		}
+		params, err := srv.GetParameters(&ssm.GetParametersInput{Names: names[i:end]})
+		if err != nil {
+			return fmt.Errorf("unable to get SSM parameters: %v", err)
+		}
+		missing = append(missing, aws.StringValueSlice(params.InvalidParameters)...)
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("task definition %s references missing SSM parameters: %s", taskDefinition, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -2,8 +2,13 @@ data "aws_organizations_organization" "org" {}
 
 // mockoon 
 resource "aws_ecr_repository" "mockoon" {
-  name  = "${var.project}_mockoon"
-  count = var.env == "dev" ? 1 : 0
+  name                 = "${var.project}_mockoon"
+  count                = var.env == "dev" ? 1 : 0
+  image_tag_mutability = var.ecr_image_tag_mutability
+
+  image_scanning_configuration {
+    scan_on_push = var.ecr_scan_on_push
+  }
 
   tags = {
     terraform = "true"
@@ -24,8 +29,13 @@ resource "aws_ecr_lifecycle_policy" "mockoon" {
 
 // backend 
 resource "aws_ecr_repository" "backend" {
-  name  = "${var.project}_backend"
-  count = var.env == "dev" ? 1 : 0
+  name                 = "${var.project}_backend"
+  count                = var.env == "dev" ? 1 : 0
+  image_tag_mutability = var.ecr_image_tag_mutability
+
+  image_scanning_configuration {
+    scan_on_push = var.ecr_scan_on_push
+  }
 
   tags = {
     terraform = "true"
@@ -38,6 +48,39 @@ resource "aws_ecr_repository_policy" "backend" {
   count      = var.env == "dev" ? 1 : 0
 }
 
+resource "aws_ecr_lifecycle_policy" "backend" {
+  repository = join("", aws_ecr_repository.backend.*.name)
+  count      = var.env == "dev" && var.ecr_keep_images > 0 ? 1 : 0
+  policy = jsonencode({
+    rules = [
+      {
+        rulePriority = 1
+        description  = "Delete untagged images"
+        selection = {
+          tagStatus   = "untagged"
+          countType   = "imageCountMoreThan"
+          countNumber = 1
+        }
+        action = {
+          type = "expire"
+        }
+      },
+      {
+        rulePriority = 2
+        description  = "Keep no more than ${var.ecr_keep_images} recent images"
+        selection = {
+          tagStatus   = "any"
+          countType   = "imageCountMoreThan"
+          countNumber = var.ecr_keep_images
+        }
+        action = {
+          type = "expire"
+        }
+      }
+    ]
+  })
+}
+
 
 // policies
 data "aws_iam_policy_document" "default_ecr_policy" {
@@ -88,6 +131,24 @@ data "aws_iam_policy_document" "default_ecr_policy" {
       values = [ data.aws_organizations_organization.org.id ]
     }
   }
+
+  dynamic "statement" {
+    for_each = length(var.ecr_pull_account_ids) > 0 ? [1] : []
+    content {
+      sid = "Cross account read ECR policy"
+      principals {
+        type        = "AWS"
+        identifiers = [for id in var.ecr_pull_account_ids : "arn:aws:iam::${id}:root"]
+      }
+      actions = [
+        "ecr:BatchCheckLayerAvailability",
+        "ecr:BatchGetImage",
+        "ecr:DescribeImages",
+        "ecr:DescribeRepositories",
+        "ecr:GetDownloadUrlForLayer"
+      ]
+    }
+  }
 }
 
 
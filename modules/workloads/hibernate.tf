@@ -0,0 +1,89 @@
+# Scale all services to zero and stop the database outside of business hours.
+# Schedules send the same event as `aws events put-events` with action.hibernate source does.
+resource "aws_cloudwatch_event_rule" "hibernate_sleep" {
+  count               = var.hibernate_sleep_schedule != "" ? 1 : 0
+  name                = "${var.project}_hibernate_sleep_${var.env}"
+  description         = "Scale ECS services to zero and stop the database"
+  schedule_expression = var.hibernate_sleep_schedule
+}
+
+resource "aws_cloudwatch_event_target" "hibernate_sleep" {
+  count     = var.hibernate_sleep_schedule != "" ? 1 : 0
+  rule      = aws_cloudwatch_event_rule.hibernate_sleep[0].name
+  target_id = "${aws_lambda_function.lambda_deploy.function_name}_hibernate_sleep"
+  arn       = aws_lambda_function.lambda_deploy.arn
+  input = jsonencode({
+    source      = "action.hibernate"
+    detail-type = "HIBERNATE"
+    detail = {
+      action = "sleep"
+    }
+  })
+}
+
+resource "aws_lambda_permission" "hibernate_sleep" {
+  count         = var.hibernate_sleep_schedule != "" ? 1 : 0
+  statement_id  = "AllowHibernateSleepFromCloudWatch"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.lambda_deploy.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.hibernate_sleep[0].arn
+}
+
+resource "aws_cloudwatch_event_rule" "hibernate_wake" {
+  count               = var.hibernate_wake_schedule != "" ? 1 : 0
+  name                = "${var.project}_hibernate_wake_${var.env}"
+  description         = "Bring back ECS services and the database stopped by hibernation"
+  schedule_expression = var.hibernate_wake_schedule
+}
+
+resource "aws_cloudwatch_event_target" "hibernate_wake" {
+  count     = var.hibernate_wake_schedule != "" ? 1 : 0
+  rule      = aws_cloudwatch_event_rule.hibernate_wake[0].name
+  target_id = "${aws_lambda_function.lambda_deploy.function_name}_hibernate_wake"
+  arn       = aws_lambda_function.lambda_deploy.arn
+  input = jsonencode({
+    source      = "action.hibernate"
+    detail-type = "HIBERNATE"
+    detail = {
+      action = "wake"
+    }
+  })
+}
+
+resource "aws_lambda_permission" "hibernate_wake" {
+  count         = var.hibernate_wake_schedule != "" ? 1 : 0
+  statement_id  = "AllowHibernateWakeFromCloudWatch"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.lambda_deploy.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.hibernate_wake[0].arn
+}
+
+# Wake stops at starting the database, services are woken up when it is available.
+# The lambda skips the events of databases other than hibernate_db_identifier.
+resource "aws_cloudwatch_event_rule" "hibernate_db_started" {
+  name        = "${var.project}_hibernate_db_started_${var.env}"
+  description = "Bring back ECS services when the hibernated database is started"
+  event_pattern = jsonencode({
+    source      = ["aws.rds"]
+    detail-type = ["RDS DB Instance Event"]
+    detail = {
+      EventID = ["RDS-EVENT-0088"]
+    }
+  })
+}
+
+resource "aws_cloudwatch_event_target" "hibernate_db_started" {
+  rule      = aws_cloudwatch_event_rule.hibernate_db_started.name
+  target_id = "${aws_lambda_function.lambda_deploy.function_name}_hibernate_db_started"
+  arn       = aws_lambda_function.lambda_deploy.arn
+}
+
+resource "aws_lambda_permission" "hibernate_db_started" {
+  statement_id  = "AllowHibernateDBStartedFromCloudWatch"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.lambda_deploy.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.hibernate_db_started.arn
+}
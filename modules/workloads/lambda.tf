@@ -31,6 +31,12 @@ resource "aws_iam_role_policy_attachment" "lambda_basic_esecution" {
 }
 
 
+resource "aws_iam_role_policy_attachment" "lambda_xray" {
+  role       = aws_iam_role.lambda_deploy_iam.name
+  policy_arn = "arn:aws:iam::aws:policy/AWSXRayDaemonWriteAccess"
+}
+
+
 resource "aws_lambda_function" "lambda_deploy" {
   filename         = "ci_lambda.zip"
   function_name    = "ci_lambda"
@@ -39,11 +45,28 @@ resource "aws_lambda_function" "lambda_deploy" {
   source_code_hash = data.archive_file.lambda.output_base64sha256
   runtime          = "go1.x"
 
+  // an invocation makes several HTTP calls, slack, grafana and github, each limited to 10 seconds
+  timeout = 60
+
+  tracing_config {
+    mode = "Active"
+  }
+
   environment {
     variables = {
-      PROJECT_NAME      = var.project
-      SLACK_WEBHOOK_URL = var.slack_deployment_webhook
-      PROJECT_ENV       = var.env
+      PROJECT_NAME               = var.project
+      SLACK_WEBHOOK_URL          = var.slack_deployment_webhook
+      SLACK_QUIET_HOURS          = var.slack_quiet_hours
+      SLACK_QUIET_HOURS_TIMEZONE = var.slack_quiet_hours_timezone
+      PROJECT_ENV                = var.env
+      GRAFANA_URL                = var.grafana_url
+      GRAFANA_API_KEY            = var.grafana_api_key
+      HIBERNATE_DB_IDENTIFIER    = var.hibernate_db_identifier
+      EMAIL_SENDER               = var.deployment_email_sender
+      EMAIL_RECIPIENTS           = join(",", var.deployment_email_recipients)
+      EVENT_RULES                = length(var.deployment_event_rules) > 0 ? jsonencode(var.deployment_event_rules) : ""
+      GITHUB_REPOSITORY          = var.github_repository
+      GITHUB_TOKEN               = var.github_token
     }
   }
 }
@@ -57,10 +80,80 @@ data "aws_iam_policy_document" "lambda_ecs" {
       "ecs:DescribeTaskDefinition",
       "ecs:ListTaskDefinitions",
       "ecs:UpdateService",
+      "ecs:ListTasks",
+      "ecs:DescribeTasks",
+      "ecs:ListServices",
+      "ecs:DescribeServices",
       "iam:PassRole"
     ]
     resources = ["*"]
   }
+
+  // hibernation suspends autoscaling of the services it scales to zero
+  statement {
+    effect = "Allow"
+    actions = [
+      "application-autoscaling:DescribeScalableTargets",
+      "application-autoscaling:RegisterScalableTarget"
+    ]
+    resources = ["*"]
+  }
+
+  statement {
+    effect = "Allow"
+    actions = [
+      "rds:StopDBInstance",
+      "rds:StartDBInstance",
+      "rds:DescribeDBInstances"
+    ]
+    resources = ["*"]
+  }
+
+  // check the secrets of task definition exist before deployment
+  statement {
+    effect    = "Allow"
+    actions   = ["ssm:GetParameters"]
+    resources = ["arn:aws:ssm:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:parameter/${var.env}/${var.project}/*"]
+  }
+
+  // incident flag and the deployments queued while the incident is active
+  statement {
+    effect = "Allow"
+    actions = [
+      "ssm:GetParameter",
+      "ssm:GetParametersByPath",
+      "ssm:PutParameter",
+      "ssm:DeleteParameter"
+    ]
+    resources = [
+      "arn:aws:ssm:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:parameter/${var.env}/${var.project}/incident",
+      "arn:aws:ssm:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:parameter/${var.env}/${var.project}/incident/*"
+    ]
+  }
+
+  // desired count of the services scaled to zero by hibernation
+  statement {
+    effect = "Allow"
+    actions = [
+      "ssm:GetParameter",
+      "ssm:PutParameter",
+      "ssm:DeleteParameter"
+    ]
+    resources = ["arn:aws:ssm:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:parameter/${var.env}/${var.project}/hibernate/*"]
+  }
+
+  // metadata of the latest deployment of every service
+  statement {
+    effect    = "Allow"
+    actions   = ["ssm:GetParameter", "ssm:PutParameter"]
+    resources = ["arn:aws:ssm:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:parameter/${var.env}/${var.project}/deployment/*"]
+  }
+
+  statement {
+    effect    = "Allow"
+    actions   = ["ses:SendEmail"]
+    resources = ["*"]
+  }
 }
 
 resource "aws_iam_policy" "lambda_ecs" {
@@ -82,14 +175,18 @@ resource "aws_cloudwatch_event_rule" "ecr_event" {
       "aws.ecr",
       "aws.ecs",
       "aws.ssm",
-      "action.production"
+      "action.production",
+      "action.hibernate",
+      "action.incident"
     ]
     detail-type = [
       "ECR Image Action",
       "ECS Deployment State Change",
       "ECS Service Action",
       "Parameter Store Change",
-      "DEPLOY"
+      "DEPLOY",
+      "HIBERNATE",
+      "INCIDENT"
     ]
   })
 }
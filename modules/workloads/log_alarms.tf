@@ -0,0 +1,30 @@
+resource "aws_cloudwatch_log_metric_filter" "backend" {
+  for_each       = { for a in var.backend_log_alarms : a.name => a }
+  name           = "${var.project}_backend_${each.key}_${var.env}"
+  log_group_name = aws_cloudwatch_log_group.backend.name
+  pattern        = each.value.pattern
+
+  metric_transformation {
+    name          = "backend_${each.key}"
+    namespace     = "${var.project}/${var.env}"
+    value         = "1"
+    default_value = "0"
+  }
+}
+
+resource "aws_cloudwatch_metric_alarm" "backend_logs" {
+  for_each            = { for a in var.backend_log_alarms : a.name => a }
+  alarm_name          = "${var.project}_backend_${each.key}_${var.env}"
+  alarm_description   = "Backend logs have at least ${each.value.threshold} lines matching ${each.value.pattern} in 5 minutes"
+  comparison_operator = "GreaterThanOrEqualToThreshold"
+  evaluation_periods  = 1
+  threshold           = each.value.threshold
+  treat_missing_data  = "notBreaching"
+  alarm_actions       = local.alarm_actions
+  ok_actions          = local.alarm_actions
+
+  namespace   = aws_cloudwatch_log_metric_filter.backend[each.key].metric_transformation[0].namespace
+  metric_name = aws_cloudwatch_log_metric_filter.backend[each.key].metric_transformation[0].name
+  period      = 300
+  statistic   = "Sum"
+}
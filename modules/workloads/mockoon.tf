@@ -43,7 +43,7 @@ resource "aws_ecs_service" "mockoon" {
   }
 
   lifecycle {
-    ignore_changes = [task_definition]
+    ignore_changes = [task_definition, desired_count]
   }
 
   tags = {
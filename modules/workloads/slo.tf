@@ -0,0 +1,72 @@
+// Backend SLO alarms based on ALB metrics, every alarm is skipped when its target is not set.
+locals {
+  alarm_actions = var.alarm_topic_arn == "" ? [] : [var.alarm_topic_arn]
+}
+
+resource "aws_cloudwatch_metric_alarm" "backend_availability" {
+  count               = var.backend_slo_availability > 0 ? 1 : 0
+  alarm_name          = "${var.project}_backend_availability_${var.env}"
+  alarm_description   = "Backend availability is below ${var.backend_slo_availability}% SLO"
+  comparison_operator = "LessThanThreshold"
+  evaluation_periods  = 3
+  threshold           = var.backend_slo_availability
+  treat_missing_data  = "notBreaching"
+  alarm_actions       = local.alarm_actions
+  ok_actions          = local.alarm_actions
+
+  metric_query {
+    id          = "availability"
+    expression  = "IF(requests > 0, 100 * (1 - FILL(errors, 0) / requests), 100)"
+    label       = "Availability, %"
+    return_data = true
+  }
+
+  metric_query {
+    id = "errors"
+    metric {
+      namespace   = "AWS/ApplicationELB"
+      metric_name = "HTTPCode_Target_5XX_Count"
+      period      = 300
+      stat        = "Sum"
+      dimensions = {
+        LoadBalancer = aws_lb.alb.arn_suffix
+        TargetGroup  = aws_alb_target_group.backend.arn_suffix
+      }
+    }
+  }
+
+  metric_query {
+    id = "requests"
+    metric {
+      namespace   = "AWS/ApplicationELB"
+      metric_name = "RequestCount"
+      period      = 300
+      stat        = "Sum"
+      dimensions = {
+        LoadBalancer = aws_lb.alb.arn_suffix
+        TargetGroup  = aws_alb_target_group.backend.arn_suffix
+      }
+    }
+  }
+}
+
+resource "aws_cloudwatch_metric_alarm" "backend_latency" {
+  count               = var.backend_slo_latency_p99 > 0 ? 1 : 0
+  alarm_name          = "${var.project}_backend_latency_${var.env}"
+  alarm_description   = "Backend p99 latency is above ${var.backend_slo_latency_p99}s SLO"
+  comparison_operator = "GreaterThanThreshold"
+  evaluation_periods  = 3
+  threshold           = var.backend_slo_latency_p99
+  treat_missing_data  = "notBreaching"
+  alarm_actions       = local.alarm_actions
+  ok_actions          = local.alarm_actions
+
+  namespace          = "AWS/ApplicationELB"
+  metric_name        = "TargetResponseTime"
+  period             = 300
+  extended_statistic = "p99"
+  dimensions = {
+    LoadBalancer = aws_lb.alb.arn_suffix
+    TargetGroup  = aws_alb_target_group.backend.arn_suffix
+  }
+}
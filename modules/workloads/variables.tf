@@ -24,6 +24,78 @@ variable "slack_deployment_webhook" {
   default = ""
 }
 
+// daily window in HH:MM-HH:MM format, when only failed deployments and tasks failing to start are reported to slack
+variable "slack_quiet_hours" {
+  default = ""
+}
+
+// IANA timezone name for slack_quiet_hours, UTC by default
+variable "slack_quiet_hours_timezone" {
+  default = ""
+}
+
+// grafana to add deployment annotations to, e.g. https://grafana.example.com
+variable "grafana_url" {
+  default = ""
+}
+
+variable "grafana_api_key" {
+  default   = ""
+  sensitive = true
+}
+
+// GitHub repository of the service images as owner/repo, to link deployments with sha-<commit> image tags to commits
+variable "github_repository" {
+  default = ""
+}
+
+// GitHub token to read commits of a private repository
+variable "github_token" {
+  default   = ""
+  sensitive = true
+}
+
+// deployment notification emails are sent with SES, the sender should be a verified identity
+variable "deployment_email_sender" {
+  default = ""
+}
+
+variable "deployment_email_recipients" {
+  default = []
+  type    = list(string)
+}
+
+// rules for events allowed to trigger deployments, with source, detail_type, repository, tag and parameter regexps, see ci_lambda README
+variable "deployment_event_rules" {
+  default = []
+  type    = any
+
+  validation {
+    condition     = alltrue([for r in var.deployment_event_rules : alltrue([for k, v in r : can(regexall(v, ""))])])
+    error_message = "Every deployment_event_rules field should be a valid regexp."
+  }
+}
+
+// schedule expressions to scale services to zero and stop the database, and to bring them back, e.g. cron(0 20 ? * MON-FRI *)
+variable "hibernate_sleep_schedule" {
+  default = ""
+}
+
+variable "hibernate_wake_schedule" {
+  default = ""
+}
+
+// database instance to stop during hibernation
+variable "hibernate_db_identifier" {
+  default = ""
+}
+
+// existing security groups added to backend service, e.g. to access resources outside of the project
+variable "backend_security_group_ids" {
+  default = []
+  type    = list(string)
+}
+
 variable "vpc_id" {
   type = string
 }
@@ -52,6 +124,81 @@ variable "backend_health_endpoint" {
   default = "/health/live"
 }
 
+// backend autoscaling capacity, autoscaling is disabled when max is 0
+variable "backend_autoscaling_min" {
+  default = 1
+  type    = number
+}
+
+variable "backend_autoscaling_max" {
+  default = 0
+  type    = number
+}
+
+// target tracking values: average CPU and memory utilization in percent, requests per task per minute, 0 disables the policy
+variable "backend_autoscaling_cpu" {
+  default = 0
+  type    = number
+}
+
+variable "backend_autoscaling_memory" {
+  default = 0
+  type    = number
+}
+
+variable "backend_autoscaling_requests" {
+  default = 0
+  type    = number
+}
+
+// backend SLO targets, availability in percent (e.g. 99.9) and p99 latency in seconds, 0 disables the alarm
+variable "backend_slo_availability" {
+  default = 0
+  type    = number
+}
+
+variable "backend_slo_latency_p99" {
+  default = 0
+  type    = number
+}
+
+// SNS topic to notify when SLO or log alarm changes state
+variable "alarm_topic_arn" {
+  default = ""
+}
+
+// alarms on the count of backend log lines matching the pattern in 5 minutes
+// https://docs.aws.amazon.com/AmazonCloudWatch/latest/logs/FilterAndPatternSyntax.html
+variable "backend_log_alarms" {
+  default = []
+  type = list(object({
+    name      = string
+    pattern   = string
+    threshold = number
+  }))
+}
+
+// customer managed key to encrypt the images bucket, enable_kms is needed as the key arn is unknown until apply
+variable "enable_kms" {
+  default = false
+  type    = bool
+}
+
+variable "kms_key_arn" {
+  default = ""
+}
+
+// write ALB access logs to S3 and query them with Athena
+variable "alb_access_logs" {
+  default = false
+  type    = bool
+}
+
+variable "alb_access_logs_retention" {
+  default = 30
+  type    = number
+}
+
 variable "zone_id" {
   type = string
 }
@@ -90,6 +237,27 @@ variable "setup_FCM_SNS" {
   default = false
 }
 
+// MUTABLE or IMMUTABLE
+variable "ecr_image_tag_mutability" {
+  default = "MUTABLE"
+}
+
+variable "ecr_scan_on_push" {
+  default = false
+}
+
+// keep no more than N recent backend images, 0 to keep all of them
+variable "ecr_keep_images" {
+  default = 0
+  type    = number
+}
+
+// accounts outside of the organization allowed to pull images
+variable "ecr_pull_account_ids" {
+  default = []
+  type    = list(string)
+}
+
 variable "ecr_lifecycle_policy" {
   type    = string
   default = <<EOF